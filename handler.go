@@ -1,4 +0,0 @@
-package main
-
-// Handler ...
-type Handler func(e Editor, buf Buffer, cmd Command) error
@@ -1,19 +1,34 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
+
+	"github.com/prologic/ed/pkg/ed"
 )
 
 var (
 	debug   bool
 	version bool
 
-	prompt string
+	prompt    string
+	history   int
+	listWidth int
+	window    int
+
+	silent     bool
+	scriptFile string
+
+	backup string
+	rope   bool
+
+	copyCommand  string
+	pasteCommand string
 )
 
 func init() {
@@ -26,6 +41,20 @@ func init() {
 	flag.BoolVarP(&debug, "debug", "d", false, "enable debug logging")
 
 	flag.StringVarP(&prompt, "prompt", "p", "> ", "prompt to use")
+	flag.IntVarP(&history, "history", "u", 0, "number of changes that can be undone (0 for unlimited)")
+	flag.IntVar(&window, "window-size", 0, "number of lines the z command prints (0 for the terminal's height)")
+	flag.IntVar(&listWidth, "list-width", 72, "width lines printed by the l command are folded at (0 to not fold them)")
+
+	flag.BoolVarP(&silent, "silent", "s", false, "script mode, read commands from stdin")
+	flag.StringVar(&scriptFile, "script", "", "script mode, read commands from the given file")
+
+	flag.StringVar(&backup, "backup", "", "keep a backup of files before writing them, file~ (simple) or file.YYYYMMDD-HHMMSS~ (timestamp)")
+	flag.Lookup("backup").NoOptDefVal = "simple"
+
+	flag.BoolVar(&rope, "rope", false, "use a buffer suited to very large files that loads them lazily")
+
+	flag.StringVar(&copyCommand, "copy-command", "", "shell command y and the other commands copy lines to the clipboard with, e.g. wl-copy")
+	flag.StringVar(&pasteCommand, "paste-command", "", "shell command x pastes lines from the clipboard with, e.g. wl-paste -n")
 }
 
 func main() {
@@ -42,32 +71,62 @@ func main() {
 		os.Exit(0)
 	}
 
-	e, err := newEditor()
+	var options []ed.Option
+
+	newBuffer := ed.NewBuffer
+	if rope {
+		newBuffer = ed.NewRopeBuffer
+	}
+	options = append(options, ed.WithBuffers(func() ed.Buffer {
+		buf := newBuffer()
+		buf.SetHistory(history)
+		return buf
+	}))
+
+	switch backup {
+	case "":
+	case "simple":
+		options = append(options, ed.WithBackup(ed.BackupSimple))
+	case "timestamp":
+		options = append(options, ed.WithBackup(ed.BackupTimestamp))
+	default:
+		log.Errorf("invalid backup style: %s", backup)
+		os.Exit(1)
+	}
+
+	if (copyCommand == "") != (pasteCommand == "") {
+		log.Errorf("--copy-command and --paste-command must be given together")
+		os.Exit(1)
+	} else if copyCommand != "" {
+		options = append(options, ed.WithClipboard(ed.NewCommandClipboard(copyCommand, pasteCommand)))
+	}
+
+	var term ed.Terminal
+
+	if scriptFile != "" {
+		f, err := os.Open(scriptFile)
+		if err != nil {
+			log.WithError(err).Error("error opening script")
+			os.Exit(1)
+		}
+		defer f.Close()
+		options = append(options, ed.WithInput(f))
+	} else if !silent {
+		var err error
+		term, err = newReadlineTerminal(prompt)
+		if err != nil {
+			log.WithError(err).Error("error creating terminal")
+			os.Exit(1)
+		}
+		options = append(options, ed.WithTerminal(term))
+	}
+
+	e, err := ed.NewEditor(options...)
 	if err != nil {
 		log.Errorf("error creating editor: %s", err)
 		os.Exit(1)
 	}
 
-	e.Handle("", cmdMove)
-	e.Handle("!", cmdShell)
-	e.Handle("=", cmdIndex)
-	e.Handle("/", cmdSearch)
-	e.Handle("a", cmdAppend)
-	e.Handle("c", cmdChange)
-	e.Handle("d", cmdDelete)
-	e.Handle("e", cmdEdit)
-	e.Handle("f", cmdFile)
-	e.Handle("i", cmdInsert)
-	e.Handle("j", cmdJoin)
-	e.Handle("n", cmdNumber)
-	e.Handle("p", cmdPrint)
-	e.Handle("q", cmdQuit)
-	e.Handle("r", cmdRead)
-	e.Handle("w", cmdWrite)
-	e.Handle("wq", cmdWriteQuit)
-	e.Handle("x", cmdPut)
-	e.Handle("y", cmdYank)
-
 	if len(flag.Args()) == 1 {
 		filename := flag.Arg(0)
 		f, err := os.Open(filename)
@@ -75,7 +134,7 @@ func main() {
 			log.WithError(err).Error("error opening file")
 			os.Exit(1)
 		}
-		if _, err = io.Copy(e, f); err != nil {
+		if _, err = e.ReadFrom(f); err != nil {
 			log.WithError(err).Error("error reading from file")
 			os.Exit(1)
 		}
@@ -83,12 +142,61 @@ func main() {
 		e.SetFilename(filename)
 	}
 
+	e.Buffer().SetModified(false)
+
+	// Changes are journaled so they can be recovered if the editor dies, in
+	// interactive sessions only as scripts can simply be run again. Run
+	// removes the journals when quitting normally.
+	if term != nil {
+		if e.Filename() != "" {
+			recoverJournal(term, e)
+		}
+		if e.Buffer().Journal() == nil {
+			e.Buffer().SetJournal(ed.NewJournal(e.Buffer()))
+		}
+	}
+
 	if prompt != "" {
 		e.SetPrompt(prompt)
 	}
+	e.SetListWidth(listWidth)
+	e.SetWindowSize(window)
 
 	if err := e.Run(); err != nil {
-		log.Errorf("error running editor: %s", err)
+		// Failed and interrupted commands in a script have already been
+		// reported
+		if err != ed.ErrCommandFailed && !errors.Is(err, ed.ErrInterrupt) {
+			log.Errorf("error running editor: %s", err)
+		}
 		os.Exit(1)
 	}
 }
+
+// recoverJournal offers to replay the journal left beside the file being
+// edited by an editor that died, which then becomes the buffer's journal, the
+// journal is removed if it's declined. The journal of an editor still running
+// is left alone.
+func recoverJournal(term ed.Terminal, e ed.Editor) {
+	name := ed.JournalName(e.Filename())
+	f, err := ed.OpenJournal(e.Filename())
+	if err == ed.ErrJournalLocked {
+		log.Errorf("%s is being edited in another session, its changes won't be recovered", e.Filename())
+		return
+	} else if err != nil {
+		return
+	}
+
+	term.SetPrompt(fmt.Sprintf("unsaved changes to %s were found, recover them? [y/n] ", e.Filename()))
+	answer, err := term.Readline()
+	term.SetPrompt(prompt)
+	if err != nil || !strings.HasPrefix(strings.ToLower(answer), "y") {
+		os.Remove(name)
+		f.Close()
+		return
+	}
+
+	if err := ed.RecoverJournal(e.Buffer(), f); err != nil {
+		log.Errorf("error recovering changes from %s: %s", name, err)
+		f.Close()
+	}
+}
@@ -1,7 +0,0 @@
-package main
-
-const (
-	modeCommand = iota
-	modeAppend
-	modeInsert
-)
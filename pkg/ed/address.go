@@ -0,0 +1,327 @@
+package ed
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Address ...
+type Address interface {
+	fmt.Stringer
+
+	IsUnspecified() bool
+	Resolve(buf Buffer, re *regexp.Regexp) error
+	Regexp() *regexp.Regexp
+	Size() int
+	Start() int
+	End() int
+}
+
+const (
+	termRelative = iota // an offset alone relative to the current line
+	termLine            // n
+	termCurrent         // .
+	termLast            // $
+	termForward         // /re/
+	termBackward        // ?re?
+	termMark            // 'x
+)
+
+// addrTerm is a single line address, a base address followed by any number
+// of + and - offsets e.g. 5, $-1, /re/+2, 'a- or +++ which are summed
+type addrTerm struct {
+	kind   int
+	line   int
+	re     *regexp.Regexp
+	mark   byte
+	offset int
+}
+
+// addrNode is either an address term or a , or ; separator
+type addrNode struct {
+	sep  byte
+	term addrTerm
+}
+
+// address is a parsed address list which is resolved against a buffer to the
+// (at most) two line numbers the command applies to
+type address struct {
+	nodes  []addrNode
+	re     *regexp.Regexp
+	count  int
+	_start int
+	_end   int
+}
+
+// NewAddress returns an already resolved address of the lines start to end
+func NewAddress(start, end int) Address {
+	count := 2
+	if start == end {
+		count = 1
+	}
+	return &address{count: count, _start: start, _end: end}
+}
+
+// parseAddress parses the address list at the start of s and returns it along
+// with the remainder of s
+func parseAddress(s string) (*address, string, error) {
+	a := &address{}
+
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return a, s, nil
+		}
+
+		if c := s[0]; c == ',' || c == ';' || c == '%' {
+			if c == '%' {
+				c = ','
+			}
+			a.nodes = append(a.nodes, addrNode{sep: c})
+			s = s[1:]
+			continue
+		}
+
+		term, rest, ok, err := parseTerm(s)
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			return a, s, nil
+		}
+
+		if n := len(a.nodes); n > 0 && a.nodes[(n-1)].sep == 0 {
+			return nil, "", ErrInvalidAddress
+		}
+
+		a.nodes = append(a.nodes, addrNode{term: term})
+		s = rest
+	}
+}
+
+// parseTerm parses a single address term at the start of s. ok is false if s
+// does not start with an address.
+func parseTerm(s string) (t addrTerm, rest string, ok bool, err error) {
+	switch c := s[0]; {
+	case c >= '0' && c <= '9':
+		i := 1
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		t.kind = termLine
+		if t.line, err = strconv.Atoi(s[:i]); err != nil {
+			return
+		}
+		s = s[i:]
+	case c == '.':
+		t.kind = termCurrent
+		s = s[1:]
+	case c == '$':
+		t.kind = termLast
+		s = s[1:]
+	case c == '/' || c == '?':
+		t.kind = termForward
+		if c == '?' {
+			t.kind = termBackward
+		}
+
+		// The closing delimiter may be omitted at the end of the line
+		var expr string
+		expr, s, _ = splitPattern(s[1:], c)
+		if expr != "" {
+			if t.re, err = regexp.Compile(expr); err != nil {
+				return
+			}
+		}
+	case c == '\'':
+		if len(s) < 2 || s[1] < 'a' || s[1] > 'z' {
+			err = ErrInvalidMark
+			return
+		}
+		t.kind = termMark
+		t.mark = s[1]
+		s = s[2:]
+	case c == '+' || c == '-' || c == '^':
+		t.kind = termRelative
+	default:
+		return t, s, false, nil
+	}
+
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" || (s[0] != '+' && s[0] != '-' && s[0] != '^') {
+			break
+		}
+
+		sign := 1
+		if s[0] != '+' {
+			sign = -1
+		}
+		s = s[1:]
+
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			t.offset += sign
+			continue
+		}
+
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return t, s, false, err
+		}
+		t.offset += sign * n
+		s = s[i:]
+	}
+
+	return t, s, true, nil
+}
+
+func (a *address) IsUnspecified() bool {
+	return a.count == 0
+}
+
+func (a *address) String() string {
+	switch a.count {
+	case 0:
+		return ""
+	case 1:
+		return strconv.Itoa(a._end)
+	default:
+		return fmt.Sprintf("%d,%d", a._start, a._end)
+	}
+}
+
+// Resolve evaluates the address list against the buffer using re for any
+// empty regular expressions. When more than two addresses are given the last
+// two are used, a , separator alone stands for 1,$ and a ; alone for .,$ with
+// ; also making the address before it the current line for the one after it.
+// Without any addresses both the start and end are the current line.
+func (a *address) Resolve(buf Buffer, re *regexp.Regexp) error {
+	var (
+		first, second = -1, -1
+		current       = buf.Index()
+		expectTerm    = true
+	)
+
+	for _, node := range a.nodes {
+		if node.sep == 0 {
+			n, err := a.evaluate(node.term, buf, current, re)
+			if err != nil {
+				return err
+			}
+			if a.re != nil {
+				re = a.re
+			}
+			second, expectTerm = n, false
+			continue
+		}
+
+		if expectTerm {
+			if first < 0 {
+				first, second = 1, buf.Size()
+				if node.sep == ';' {
+					first = current
+				}
+			} else {
+				second = first
+			}
+			continue
+		}
+
+		if second < 0 || second > buf.Size() {
+			return ErrAddressOutOfRange
+		}
+		if node.sep == ';' {
+			current = second
+		}
+		first, expectTerm = second, true
+	}
+
+	if !expectTerm && (second < 0 || second > buf.Size()) {
+		return ErrAddressOutOfRange
+	}
+
+	a.count = 0
+	if second >= 0 {
+		a.count = 1
+		if first >= 0 {
+			a.count = 2
+		}
+	}
+
+	if a.count == 0 {
+		second = current
+	}
+	if a.count <= 1 {
+		first = second
+	}
+
+	if first > second {
+		return ErrInvalidAddress
+	}
+
+	a._start, a._end = first, second
+
+	return nil
+}
+
+// evaluate returns the line number of a single address term
+func (a *address) evaluate(t addrTerm, buf Buffer, current int, re *regexp.Regexp) (int, error) {
+	var n int
+
+	switch t.kind {
+	case termRelative, termCurrent:
+		n = current
+	case termLine:
+		n = t.line
+	case termLast:
+		n = buf.Size()
+	case termForward, termBackward:
+		if t.re != nil {
+			re = t.re
+		}
+		if re == nil {
+			return 0, ErrNoExpressionSpecified
+		}
+		a.re = re
+
+		n = buf.Search(re, current, t.kind == termForward)
+		if n == 0 {
+			return 0, ErrNoMatch
+		}
+	case termMark:
+		n = buf.Mark(t.mark)
+		if n == 0 {
+			return 0, ErrUndefinedMark
+		}
+	}
+
+	n += t.offset
+	if n < 0 {
+		return 0, ErrAddressOutOfRange
+	}
+
+	return n, nil
+}
+
+// Regexp returns the last regular expression used by a search address, if any
+func (a *address) Regexp() *regexp.Regexp {
+	return a.re
+}
+
+func (a *address) Size() int {
+	return (a._end - a._start) + 1
+}
+
+func (a *address) Start() int {
+	return a._start
+}
+
+func (a *address) End() int {
+	return a._end
+}
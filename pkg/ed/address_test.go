@@ -0,0 +1,75 @@
+package ed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExecuteAddresses(t *testing.T) {
+	lines := []string{
+		"alpha", "beta", "gamma", "delta", "epsilon",
+		"zeta", "eta", "theta", "iota", "kappa",
+	}
+
+	tests := []struct {
+		name  string
+		setup []string
+		line  string
+		want  string
+		err   error
+	}{
+		{"current", nil, ".p", "kappa", nil},
+		{"last", []string{"2"}, "$p", "kappa", nil},
+		{"number", nil, "3p", "gamma", nil},
+		{"range", nil, "2,4p", "beta\ngamma\ndelta", nil},
+		{"whole buffer", nil, ",p", strings.Join(lines, "\n"), nil},
+		{"current to last", []string{"8"}, ";p", "theta\niota\nkappa", nil},
+		{"last minus offset", nil, "$-5p", "epsilon", nil},
+		{"offset without base", []string{"2"}, "-p", "alpha", nil},
+		{"repeated plus", []string{"2"}, "+++p", "epsilon", nil},
+		{"repeated minus", nil, "--p", "theta", nil},
+		{"number and offsets", nil, "3+2-1p", "delta", nil},
+		{"forward search", []string{"1"}, "/eta/p", "beta", nil},
+		{"forward search wraps", []string{"9"}, "/eta/p", "beta", nil},
+		{"backward search", []string{"5"}, "?eta?p", "beta", nil},
+		{"backward search wraps", []string{"1"}, "?eta?p", "theta", nil},
+		{"search range", []string{"1"}, "/gamma/,/zeta/p", "gamma\ndelta\nepsilon\nzeta", nil},
+		{"search from first address", []string{"1"}, "/gamma/;/eta/p", "gamma\ndelta\nepsilon\nzeta", nil},
+		{"search with offset", []string{"1"}, "/delta/+1p", "epsilon", nil},
+		{"previous search", []string{"1", "/eta/"}, "//p", "zeta", nil},
+		{"no match", nil, "/omega/p", "", ErrNoMatch},
+		{"mark", []string{"4kx"}, "'xp", "delta", nil},
+		{"mark range", []string{"2ka", "4kb"}, "'a,'bp", "beta\ngamma\ndelta", nil},
+		{"unset mark", nil, "'yp", "", ErrUndefinedMark},
+		{"more than two addresses", nil, "1,2,3p", "beta\ngamma", nil},
+		{"more than two with semicolons", []string{"1"}, "2;+1;+1p", "gamma\ndelta", nil},
+		{"out of range", nil, "11p", "", ErrAddressOutOfRange},
+		{"backwards range", nil, "4,2p", "", ErrInvalidAddress},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, "", lines...)
+			for _, line := range test.setup {
+				if _, err := e.Execute(line); err != nil {
+					t.Fatalf("Execute(%q) failed: %s", line, err)
+				}
+			}
+
+			res, err := e.Execute(test.line)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("Execute(%q) gave %v, want %v", test.line, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute(%q) failed: %s", test.line, err)
+			}
+			if got := strings.TrimSuffix(res.Output, "\n"); got != test.want {
+				t.Errorf("Execute(%q) printed %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
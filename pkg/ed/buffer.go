@@ -0,0 +1,622 @@
+package ed
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Buffer ...
+type Buffer interface {
+	io.Reader
+	io.Writer
+	io.ReaderFrom
+	io.WriterTo
+
+	Filename() string
+	SetFilename(filename string)
+
+	Index() int
+	Size() int
+	Clear()
+
+	Append(line string)
+
+	Current() string
+	Search(re *regexp.Regexp, from int, forward bool) int
+	Mark(name byte) int
+	SetMark(addr Address, name byte) error
+
+	Delete(addr Address)
+	Move(addr Address) error
+	Relocate(addr Address, dest int) error
+	Transfer(addr Address, dest int) error
+	Select(addr Address) []string
+	Substitute(addr Address, re *regexp.Regexp, repl string, nth int, global bool) error
+
+	Global(addr Address, re *regexp.Regexp, invert bool) error
+	NextGlobal() int
+	ClearGlobal()
+
+	Modified() bool
+	SetModified(modified bool)
+
+	Journal() Journal
+	SetJournal(j Journal)
+	Replay(r io.Reader) error
+
+	Unterminated() int
+	WriteRange(w io.Writer, addr Address) (int64, error)
+
+	Checkpoint()
+	Undo() error
+	Redo() error
+	SetHistory(n int)
+}
+
+// line is a single line of text in the buffer, lines are referenced by
+// pointer so they keep their identity as other lines are added or removed
+type line struct {
+	text   string
+	marked bool
+}
+
+type buffer struct {
+	filename string
+
+	index  int
+	lines  lineStore
+	marks  map[byte]*line
+	global []*line
+	gnext  int
+
+	modified bool
+	journal  Journal
+
+	// The line ending of the file read into the buffer, \r\n lines are only
+	// recognised if every line ends that way, and the last line read if it
+	// had no line ending
+	crlf         bool
+	unterminated *line
+
+	// mapped is the file mapped into memory by load, the lines of a rope
+	// buffer refer to it until they're used, and mappedFile describes it
+	mapped     []byte
+	mappedFile os.FileInfo
+
+	txn     *transaction
+	undo    []*transaction
+	redo    []*transaction
+	history int
+}
+
+// Filename returns the buffer's default filename, that of the file being
+// edited
+func (b *buffer) Filename() string {
+	return b.filename
+}
+
+func (b *buffer) SetFilename(filename string) {
+	b.filename = filename
+}
+
+// Modified returns true if the buffer has been changed since it was last
+// marked unmodified, after being read from or written to a file
+func (b *buffer) Modified() bool {
+	return b.modified
+}
+
+// SetModified sets whether the buffer has been modified
+func (b *buffer) SetModified(modified bool) {
+	b.modified = modified
+}
+
+func (b *buffer) Clear() {
+	b.remove(0, b.lines.Len())
+	b.index = 0
+	b.unterminated = nil
+	b.unmap()
+}
+
+// unmap unmaps the file mapped by load once no lines in the buffer refer to
+// it. Lines removed from the buffer, including those kept for undo, have
+// been copied from the file, see ropeNode.materialize.
+func (b *buffer) unmap() {
+	if b.mapped == nil {
+		return
+	}
+	if err := munmapFile(b.mapped); err != nil {
+		log.Debugf("error unmapping file: %s", err)
+	}
+	b.mapped, b.mappedFile = nil, nil
+}
+
+// release copies the lines that are still in the file filename, if it's the
+// file the buffer has mapped, and unmaps it so that it can be overwritten
+func (b *buffer) release(filename string) {
+	if b.mapped == nil {
+		return
+	}
+	if fi, err := os.Stat(filename); err != nil || !os.SameFile(fi, b.mappedFile) {
+		return
+	}
+	b.lines.(*ropeStore).materializeAll()
+	b.unmap()
+}
+
+func (b *buffer) Index() int {
+	return b.index
+}
+
+func (b *buffer) Size() int {
+	return b.lines.Len()
+}
+
+func (b *buffer) Append(text string) {
+	b.insert(b.index, &line{text: text})
+	b.index++
+}
+
+func (b *buffer) Current() string {
+	if b.lines.Len() == 0 {
+		return ""
+	}
+
+	return b.lines.At(b.index - 1).text
+}
+
+// Search returns the number of the first line after from, or before it if
+// forward is false, that matches re wrapping around the buffer if necessary.
+// It returns zero if no line matches.
+func (b *buffer) Search(re *regexp.Regexp, from int, forward bool) int {
+	i := from
+	for n := b.lines.Len(); n > 0; n-- {
+		if forward {
+			if i++; i > b.lines.Len() {
+				i = 1
+			}
+		} else {
+			if i--; i < 1 {
+				i = b.lines.Len()
+			}
+		}
+
+		if re.MatchString(b.lines.At(i - 1).text) {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// Mark returns the line number of the line marked with name, or zero if there
+// is no such mark
+func (b *buffer) Mark(name byte) int {
+	l, ok := b.marks[name]
+	if !ok {
+		return 0
+	}
+	return b.find(l)
+}
+
+// SetMark marks the addressed line with name, a lower case letter. The mark
+// stays with the line as lines are added or removed before it and is removed
+// along with the line.
+func (b *buffer) SetMark(addr Address, name byte) error {
+	if name < 'a' || name > 'z' {
+		return ErrInvalidMark
+	}
+
+	n := addr.End()
+	if n < 1 || n > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+
+	if b.marks == nil {
+		b.marks = make(map[byte]*line)
+	}
+	b.marks[name] = b.lines.At(n - 1)
+
+	return nil
+}
+
+func (b *buffer) Delete(addr Address) {
+	start, end := addr.Start(), addr.End()
+
+	if start < 1 || end > b.lines.Len() || start > end {
+		return
+	}
+
+	b.remove((start - 1), end)
+
+	if b.lines.Len() == 0 {
+		b.index = 0
+	} else {
+		b.index = end - (end - start)
+	}
+	if b.index > b.lines.Len() {
+		b.index = b.lines.Len()
+	}
+}
+
+// Move makes the last addressed line the current line, the address 0 is
+// valid and positions the buffer before the first line
+func (b *buffer) Move(addr Address) error {
+	n := addr.End()
+	if n < 0 || n > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+	b.index = n
+	return nil
+}
+
+// Relocate moves the addressed lines to after the line dest which must not
+// be within them. The moved lines keep their marks and the last of them
+// becomes the current line.
+func (b *buffer) Relocate(addr Address, dest int) error {
+	start, end := addr.Start(), addr.End()
+	if start < 1 || end > b.lines.Len() || dest < 0 || dest > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+
+	if dest >= start && dest < end {
+		return ErrInvalidDestination
+	}
+
+	// to is where the lines are inserted once they've been removed
+	to := dest
+	if dest >= end {
+		to -= end - start + 1
+	}
+
+	lines := b.lines.Slice((start - 1), end)
+	c := change{op: opMove, at: (start - 1), to: to, lines: lines}
+	b.apply(&c, false)
+	b.record(c)
+	b.journalChange(c)
+
+	b.index = to + len(lines)
+
+	return nil
+}
+
+// Transfer copies the addressed lines to after the line dest, the last of
+// the copies becomes the current line
+func (b *buffer) Transfer(addr Address, dest int) error {
+	start, end := addr.Start(), addr.End()
+	if start < 1 || end > b.lines.Len() || dest < 0 || dest > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+
+	lines := make([]*line, 0, (end - start + 1))
+	for _, l := range b.lines.Slice((start - 1), end) {
+		lines = append(lines, &line{text: l.text})
+	}
+	b.insert(dest, lines...)
+
+	b.index = dest + len(lines)
+
+	return nil
+}
+
+func (b *buffer) Select(addr Address) []string {
+	if addr.Start() < 1 || addr.End() > b.lines.Len() {
+		return nil
+	}
+
+	var lines []string
+
+	for i := addr.Start(); i <= addr.End(); i++ {
+		lines = append(lines, b.lines.At(i-1).text)
+	}
+
+	return lines
+}
+
+func (b *buffer) Substitute(addr Address, re *regexp.Regexp, repl string, nth int, global bool) error {
+	start, end := addr.Start(), addr.End()
+	if start < 1 || end > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+
+	matched := false
+	for i := start; i <= end; i++ {
+		if text, ok := substitute(re, b.lines.At(i-1).text, repl, nth, global); ok {
+			b.replace((i - 1), text)
+			b.index = i
+			matched = true
+		}
+	}
+
+	if !matched {
+		return ErrNoMatch
+	}
+
+	return nil
+}
+
+// Global marks every line in the address range (the whole buffer if
+// unspecified) that matches re, or doesn't match if invert is true, for a
+// subsequent pass over them with NextGlobal.
+func (b *buffer) Global(addr Address, re *regexp.Regexp, invert bool) error {
+	if b.global != nil {
+		return ErrNestedGlobal
+	}
+
+	start, end := 1, b.lines.Len()
+	if !addr.IsUnspecified() {
+		start, end = addr.Start(), addr.End()
+	}
+
+	if start < 1 || end > b.lines.Len() {
+		return ErrAddressOutOfRange
+	}
+
+	b.global, b.gnext = make([]*line, 0), 0
+	for _, line := range b.lines.Slice((start - 1), end) {
+		if re.MatchString(line.text) != invert {
+			line.marked = true
+			b.global = append(b.global, line)
+		}
+	}
+
+	return nil
+}
+
+// NextGlobal unmarks the next line marked by Global and returns its current
+// line number. Lines deleted since they were marked are skipped. It returns
+// zero once no marked lines remain.
+func (b *buffer) NextGlobal() int {
+	for b.gnext < len(b.global) {
+		line := b.global[b.gnext]
+		b.gnext++
+		if !line.marked {
+			continue
+		}
+		line.marked = false
+		if n := b.find(line); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// ClearGlobal unmarks any remaining lines marked by Global
+func (b *buffer) ClearGlobal() {
+	b.unmark(b.global)
+	b.global, b.gnext = nil, 0
+}
+
+// find returns the line number of the given line searching outwards from the
+// current line as that is where the next marked line usually is, or zero if
+// the line is no longer in the buffer.
+func (b *buffer) find(l *line) int {
+	for i, j := b.index, b.index+1; i > 0 || j <= b.lines.Len(); i, j = i-1, j+1 {
+		if j <= b.lines.Len() && b.lines.At(j-1) == l {
+			return j
+		}
+		if i > 0 && i <= b.lines.Len() && b.lines.At(i-1) == l {
+			return i
+		}
+	}
+	return 0
+}
+
+// insert inserts lines before the (zero based) index at, all changes to the
+// buffer's lines go through insert, remove and replace so they can be undone
+func (b *buffer) insert(at int, lines ...*line) {
+	b.lines.Insert(at, lines)
+	b.record(change{op: opInsert, at: at, lines: lines})
+	b.journalChange(change{op: opInsert, at: at, lines: lines})
+}
+
+// remove removes the lines with (zero based) indexes start up to end
+func (b *buffer) remove(start, end int) []*line {
+	lines := b.lines.Slice(start, end)
+	b.unmark(lines)
+	b.dropMarks(lines)
+	b.lines.Remove(start, end)
+	b.record(change{op: opRemove, at: start, lines: lines})
+	b.journalChange(change{op: opRemove, at: start, lines: lines})
+	return lines
+}
+
+// replace replaces the text of the line at the (zero based) index at
+func (b *buffer) replace(at int, text string) {
+	l := b.lines.At(at)
+	b.record(change{op: opReplace, at: at, lines: []*line{l}, text: l.text})
+	l.text = text
+	b.journalChange(change{op: opReplace, at: at, text: text})
+}
+
+// dropMarks removes any marks set on lines being removed from the buffer
+func (b *buffer) dropMarks(lines []*line) {
+	if len(b.marks) == 0 {
+		return
+	}
+
+	removed := make(map[*line]bool, len(lines))
+	for _, line := range lines {
+		removed[line] = true
+	}
+
+	for name, line := range b.marks {
+		if removed[line] {
+			delete(b.marks, name)
+		}
+	}
+}
+
+func (b *buffer) unmark(lines []*line) {
+	for _, line := range lines {
+		line.marked = false
+	}
+}
+
+func (b *buffer) Read(p []byte) (n int, err error) {
+	return
+}
+
+func (b *buffer) Write(p []byte) (n int, err error) {
+	return
+}
+
+// ReadFrom reads lines from r after the current line. If the buffer is empty
+// it takes on the line ending of r, \r\n if every line ends that way, and if
+// the last line has no line ending it is written back without one.
+func (b *buffer) ReadFrom(r io.Reader) (n int64, err error) {
+	if f, ok := r.(*os.File); ok {
+		if n, ok, err = b.load(f); ok || err != nil {
+			return
+		}
+	}
+
+	var (
+		lines []string
+		crlf  = true
+		last  bool
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		s, err := br.ReadString('\n')
+		n += int64(len(s))
+		if strings.HasSuffix(s, "\n") {
+			s = s[:(len(s) - 1)]
+			crlf = crlf && strings.HasSuffix(s, "\r")
+			lines = append(lines, s)
+		} else if s != "" {
+			lines = append(lines, s)
+			last = true
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Debugf("error reading from reader: %s", err)
+			return n, err
+		}
+	}
+
+	terminated := len(lines)
+	if last {
+		terminated--
+	}
+	if b.lines.Len() == 0 {
+		b.crlf = crlf && terminated > 0
+	}
+
+	read := make([]*line, len(lines))
+	for i, s := range lines {
+		if b.crlf && i < terminated {
+			s = strings.TrimSuffix(s, "\r")
+		}
+		read[i] = &line{text: s}
+	}
+	b.insert(b.index, read...)
+	b.index += len(read)
+	if last {
+		b.unterminated = b.lines.At(b.index - 1)
+	}
+
+	return
+}
+
+// load loads the file f into an empty rope buffer outside of an undo
+// transaction, as when opening a file, by mapping it into memory. It returns
+// false if f can't be loaded this way and must be read normally.
+func (b *buffer) load(f *os.File) (int64, bool, error) {
+	rope, ok := b.lines.(*ropeStore)
+	if !ok || rope.Len() > 0 || b.txn != nil {
+		return 0, false, nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() == 0 || int64(int(fi.Size())) != fi.Size() {
+		return 0, false, nil
+	}
+	if offset, err := f.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		return 0, false, nil
+	}
+
+	data, err := mmapFile(f, fi.Size())
+	if err != nil {
+		log.Debugf("error mapping file into memory: %s", err)
+		return 0, false, err
+	}
+
+	newlines := bytes.Count(data, []byte("\n"))
+	b.crlf = newlines > 0 && bytes.Count(data, []byte("\r\n")) == newlines
+
+	b.unmap()
+	b.mapped, b.mappedFile = data, fi
+	rope.Load(data, b.crlf)
+	b.index = rope.Len()
+	b.modified = true
+	if data[(len(data)-1)] != '\n' {
+		b.unterminated = rope.At(b.index - 1)
+	}
+
+	return int64(len(data)), true, nil
+}
+
+// Unterminated returns the line number of the line read without a line
+// ending, or zero if there is none. It's written without one if it's the
+// last line written.
+func (b *buffer) Unterminated() int {
+	if b.unterminated == nil {
+		return 0
+	}
+	return b.find(b.unterminated)
+}
+
+func (b *buffer) WriteTo(w io.Writer) (n int64, err error) {
+	return b.write(w, 0, b.lines.Len())
+}
+
+// WriteRange writes the addressed lines to w with the buffer's line ending
+func (b *buffer) WriteRange(w io.Writer, addr Address) (int64, error) {
+	start, end := addr.Start(), addr.End()
+	if start < 1 || end > b.lines.Len() || start > end {
+		return 0, ErrAddressOutOfRange
+	}
+	return b.write(w, (start - 1), end)
+}
+
+// write writes the lines with (zero based) indexes start up to end to w
+func (b *buffer) write(w io.Writer, start, end int) (n int64, err error) {
+	eol := "\n"
+	if b.crlf {
+		eol = "\r\n"
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, line := range b.lines.Slice(start, end) {
+		bw.WriteString(line.text)
+		n += int64(len(line.text))
+		if line != b.unterminated || i < (end-start-1) {
+			bw.WriteString(eol)
+			n += int64(len(eol))
+		}
+	}
+	err = bw.Flush()
+	return
+}
+
+// NewBuffer returns an empty buffer which holds its lines in a slice
+func NewBuffer() Buffer {
+	return &buffer{lines: &sliceStore{}}
+}
+
+// NewRopeBuffer returns an empty buffer for large files which holds its lines
+// in a rope. Lines are inserted and removed in logarithmic time and a file
+// read into the empty buffer is mapped into memory and only split into lines
+// as they're used. The file mustn't be changed or truncated by another
+// program, such as a log rotated with copytruncate, while it's being edited.
+func NewRopeBuffer() Buffer {
+	return &buffer{lines: &ropeStore{}}
+}
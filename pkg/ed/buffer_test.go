@@ -0,0 +1,124 @@
+package ed
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+const benchmarkLines = 1000000
+
+var bufferTypes = []struct {
+	name      string
+	newBuffer func() Buffer
+}{
+	{"slice", NewBuffer},
+	{"rope", NewRopeBuffer},
+}
+
+// benchmarkFile writes a file of benchmarkLines lines and returns its name
+func benchmarkFile(b *testing.B) string {
+	f, err := ioutil.TempFile("", "ed-benchmark-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for i := 0; i < benchmarkLines; i++ {
+		fmt.Fprintf(w, "%d the quick brown fox jumps over the lazy dog\n", i)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+// loadBuffer returns a new buffer of the given type with filename read into it
+func loadBuffer(b *testing.B, newBuffer func() Buffer, filename string) Buffer {
+	f, err := os.Open(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	buf := newBuffer()
+	if _, err := buf.ReadFrom(f); err != nil {
+		b.Fatal(err)
+	}
+	return buf
+}
+
+func BenchmarkLoad(b *testing.B) {
+	filename := benchmarkFile(b)
+	defer os.Remove(filename)
+
+	for _, bt := range bufferTypes {
+		b.Run(bt.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				loadBuffer(b, bt.newBuffer, filename)
+			}
+		})
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	filename := benchmarkFile(b)
+	defer os.Remove(filename)
+
+	for _, bt := range bufferTypes {
+		b.Run(bt.name, func(b *testing.B) {
+			buf := loadBuffer(b, bt.newBuffer, filename)
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				n := rng.Intn(buf.Size())
+				buf.Move(NewAddress(n, n))
+				buf.Append("inserted")
+			}
+		})
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	filename := benchmarkFile(b)
+	defer os.Remove(filename)
+
+	for _, bt := range bufferTypes {
+		b.Run(bt.name, func(b *testing.B) {
+			buf := loadBuffer(b, bt.newBuffer, filename)
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if buf.Size() == 0 {
+					b.StopTimer()
+					buf = loadBuffer(b, bt.newBuffer, filename)
+					b.StartTimer()
+				}
+				n := rng.Intn(buf.Size()) + 1
+				buf.Delete(NewAddress(n, n))
+			}
+		})
+	}
+}
+
+func BenchmarkSelect(b *testing.B) {
+	filename := benchmarkFile(b)
+	defer os.Remove(filename)
+
+	for _, bt := range bufferTypes {
+		b.Run(bt.name, func(b *testing.B) {
+			buf := loadBuffer(b, bt.newBuffer, filename)
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				n := rng.Intn(buf.Size()) + 1
+				buf.Select(NewAddress(n, n))
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package ed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Clipboard provides the clipboard the editor stores yanked and deleted lines
+// in and x puts from by default, such as the system clipboard. The editor
+// keeps its own copy of the lines and uses that if the provider fails.
+type Clipboard interface {
+	Copy(ctx context.Context, lines []string) error
+	Paste(ctx context.Context) ([]string, error)
+}
+
+// commandClipboard is a Clipboard that runs one shell command to copy lines,
+// which are written to its standard input, and another to paste them, which
+// are read from its standard output
+type commandClipboard struct {
+	copy  string
+	paste string
+}
+
+// NewCommandClipboard returns a Clipboard that runs the shell commands copy
+// and paste, e.g. xclip -i -selection clipboard and xclip -o -selection
+// clipboard, wl-copy and wl-paste -n or pbcopy and pbpaste
+func NewCommandClipboard(copy, paste string) Clipboard {
+	return &commandClipboard{copy: copy, paste: paste}
+}
+
+func (c *commandClipboard) Copy(ctx context.Context, lines []string) error {
+	var data bytes.Buffer
+	for _, line := range lines {
+		data.WriteString(line)
+		data.WriteByte('\n')
+	}
+
+	// Copy commands such as xclip stay in the background to serve the
+	// clipboard, their output goes nowhere so that they aren't waited for
+	command := fmt.Sprintf("(%s) >/dev/null 2>&1", c.copy)
+	if _, err := execShell(ctx, "", command, &data, nil, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *commandClipboard) Paste(ctx context.Context) ([]string, error) {
+	res, err := execShell(ctx, "", c.paste, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSuffix(string(res.Output), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
@@ -0,0 +1,192 @@
+package ed
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// lineCommands are the commands that operate on the addressed lines
+	// themselves and so can't be given the address 0
+	lineCommands = map[string]bool{
+		"": true, "c": true, "d": true, "j": true, "k": true, "l": true,
+		"m": true, "n": true, "p": true, "s": true, "t": true, "y": true,
+	}
+
+	// suffixCommands are the commands that can be followed by the print
+	// suffixes p, n and l to print the current line afterwards
+	suffixCommands = map[string]bool{
+		"U": true, "d": true, "j": true, "k": true, "l": true, "m": true,
+		"n": true, "p": true, "s": true, "t": true, "u": true, "x": true,
+		"y": true, "z": true,
+	}
+
+	// printCommands are the commands that print lines in the format given
+	// by their print suffixes rather than printing the current line again
+	printCommands = map[string]bool{"l": true, "n": true, "p": true, "z": true}
+)
+
+// Command ...
+type Command interface {
+	fmt.Stringer
+
+	Validate(buffer Buffer, re *regexp.Regexp) error
+
+	Addr() Address
+	Arg(i int) string
+	Args() string
+	Cmd() string
+	Print() string
+}
+
+type command struct {
+	addr  *address
+	cmd   string
+	args  []string
+	raw   string
+	print string
+}
+
+func (c command) String() string {
+	args := strings.Join(c.args, " ")
+	return fmt.Sprintf("%s%s %s%s", c.addr.String(), c.cmd, args, c.print)
+}
+
+func (c command) Validate(buffer Buffer, re *regexp.Regexp) error {
+	if err := c.addr.Resolve(buffer, re); err != nil {
+		return err
+	}
+	if lineCommands[c.cmd] && c.addr.Start() < 1 {
+		return ErrAddressOutOfRange
+	}
+	return nil
+}
+
+func (c command) Addr() Address {
+	return c.addr
+}
+
+func (c command) Arg(i int) string {
+	if i < len(c.args) {
+		return c.args[i]
+	}
+	return ""
+}
+
+func (c command) Args() string {
+	return c.raw
+}
+
+func (c command) Cmd() string {
+	return c.cmd
+}
+
+// Print returns the print suffixes the command was given, any of p, n and l
+func (c command) Print() string {
+	return c.print
+}
+
+// ParseCommand parses a command line into its address list, the command
+// itself (a single character or wq), the command's arguments and any print
+// suffixes following them
+func ParseCommand(line string) (Command, error) {
+	addr, rest, err := parseAddress(line)
+	if err != nil {
+		return nil, err
+	}
+
+	// A newline alone is equivalent to +1p
+	if len(addr.nodes) == 0 && rest == "" {
+		addr.nodes = []addrNode{{term: addrTerm{kind: termRelative, offset: 1}}}
+	}
+
+	var name string
+	if rest != "" {
+		_, size := utf8.DecodeRuneInString(rest)
+		name, rest = rest[:size], rest[size:]
+		if name == "w" && strings.HasPrefix(rest, "q") {
+			name, rest = "wq", rest[1:]
+		}
+	}
+
+	var print string
+	if suffixCommands[name] {
+		if rest, print, err = splitSuffix(name, rest); err != nil {
+			return nil, err
+		}
+	}
+
+	args := strings.Split(strings.TrimSpace(rest), " ")
+
+	return command{addr, name, args, rest, print}, nil
+}
+
+// splitSuffix splits the arguments of the command name from the print
+// suffixes that follow them. The arguments are the mark of k, the register
+// "x of d, j, x and y, the destination address of m and t, the window size
+// of z and the regular expression, replacement and remaining flags of s.
+func splitSuffix(name, rest string) (args, print string, err error) {
+	switch name {
+	case "k":
+		_, size := utf8.DecodeRuneInString(rest)
+		args, print = rest[:size], rest[size:]
+	case "d", "j", "x", "y":
+		if arg := strings.TrimLeft(rest, " "); strings.HasPrefix(arg, `"`) {
+			_, size := utf8.DecodeRuneInString(arg[1:])
+			n := len(rest) - len(arg) + 1 + size
+			args, print = rest[:n], rest[n:]
+		} else {
+			print = rest
+		}
+	case "m", "t":
+		_, after, err := parseAddress(rest)
+		if err != nil {
+			return "", "", err
+		}
+		args, print = rest[:(len(rest)-len(after))], after
+	case "s":
+		args, print = splitSubstitutionSuffix(rest)
+	case "z":
+		n := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		args, print = rest[:n], rest[n:]
+	default:
+		print = rest
+	}
+
+	print = strings.TrimSpace(print)
+	if strings.Trim(print, "pnl") != "" {
+		return "", "", ErrInvalidSuffix
+	}
+	return args, print, nil
+}
+
+// splitSubstitutionSuffix removes the print suffixes from the flags of an
+// s command. Like GNU ed if the last delimiter is omitted the last line
+// affected is printed as if the p suffix were given.
+func splitSubstitutionSuffix(rest string) (args, print string) {
+	if rest == "" || rest[0] == ' ' || rest[0] == '\\' {
+		return rest, ""
+	}
+
+	delim := rest[0]
+	_, after, ok := splitDelim(rest[1:], delim)
+	if !ok {
+		return rest, ""
+	}
+	_, flags, ok := splitDelim(after, delim)
+	if !ok {
+		return rest, "p"
+	}
+
+	args = rest[:(len(rest) - len(flags))]
+	for _, c := range flags {
+		if strings.ContainsRune("pnl", c) {
+			print += string(c)
+		} else {
+			args += string(c)
+		}
+	}
+	return args, print
+}
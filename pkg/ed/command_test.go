@@ -0,0 +1,136 @@
+package ed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitSuffix(t *testing.T) {
+	tests := []struct {
+		name  string
+		rest  string
+		args  string
+		print string
+		err   error
+	}{
+		{"p", "", "", "", nil},
+		{"p", "n", "", "n", nil},
+		{"d", "pl", "", "pl", nil},
+		{"d", "x", "", "", ErrInvalidSuffix},
+		{"d", `"a`, `"a`, "", nil},
+		{"d", `"ap`, `"a`, "p", nil},
+		{"d", ` "a p`, ` "a`, "p", nil},
+		{"y", `"p`, `"p`, "", nil},
+		{"y", `"pp`, `"p`, "p", nil},
+		{"j", "n", "", "n", nil},
+		{"x", `"b`, `"b`, "", nil},
+		{"k", "a", "a", "", nil},
+		{"k", "pp", "p", "p", nil},
+		{"k", "ab", "", "", ErrInvalidSuffix},
+		{"m", "0", "0", "", nil},
+		{"m", "$p", "$", "p", nil},
+		{"m", "'pn", "'p", "n", nil},
+		{"t", " /re/+1l", " /re/+1", "l", nil},
+		{"t", "2q", "", "", ErrInvalidSuffix},
+		{"s", "/a/b/", "/a/b/", "", nil},
+		{"s", "/a/b/gp", "/a/b/g", "p", nil},
+		{"s", "/a/b/n3l", "/a/b/3", "nl", nil},
+		{"s", "/a/b", "/a/b", "p", nil},
+		{"s", "|a|b|p", "|a|b|", "p", nil},
+		{"s", `/a\/p/b/`, `/a\/p/b/`, "", nil},
+		{"s", "", "", "", nil},
+		{"s", "g", "g", "", nil},
+		{"z", "", "", "", nil},
+		{"z", "20", "20", "", nil},
+		{"z", "20n", "20", "n", nil},
+		{"z", "x", "", "", ErrInvalidSuffix},
+	}
+
+	for _, test := range tests {
+		args, print, err := splitSuffix(test.name, test.rest)
+		if err != test.err {
+			t.Errorf("splitSuffix(%q, %q) gave error %v, want %v", test.name, test.rest, err, test.err)
+			continue
+		}
+		if args != test.args || print != test.print {
+			t.Errorf("splitSuffix(%q, %q) = %q, %q, want %q, %q", test.name, test.rest, args, print, test.args, test.print)
+		}
+	}
+}
+
+func TestExecuteSuffixes(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"2dp", "gamma\n"},
+		{`2d"ap`, "gamma\n"},
+		{"2ka", ""},
+		{"2kpn", "3\tgamma\n"},
+		{"1m$l", "alpha$\n"},
+		{"2t0p", "beta\n"},
+		{"s/gamma/g", "g\n"},
+		{"s/gamma/G/l", "G$\n"},
+		{"1z1n", "1\talpha\n"},
+	}
+
+	for _, test := range tests {
+		e, _ := newTestEditor(t, "", "alpha", "beta", "gamma")
+		res, err := e.Execute(test.line)
+		if err != nil {
+			t.Errorf("Execute(%q) failed: %s", test.line, err)
+			continue
+		}
+		if res.Output != test.want {
+			t.Errorf("Execute(%q) printed %q, want %q", test.line, res.Output, test.want)
+		}
+	}
+}
+
+func TestExecuteScroll(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []string
+		line  string
+		want  string
+		index int
+		err   error
+	}{
+		{"after current line", []string{"1"}, "z", "beta,gamma", 3, nil},
+		{"repeated", []string{"1", "z"}, "z", "delta,epsilon", 5, nil},
+		{"from address", nil, "1z", "alpha,beta", 2, nil},
+		{"window size", []string{"1"}, "z3", "beta,gamma,delta", 4, nil},
+		{"window size kept", []string{"1", "z3"}, "1z", "alpha,beta,gamma", 3, nil},
+		{"end of buffer", nil, "4z", "delta,epsilon", 5, nil},
+		{"last line", nil, "$z", "epsilon", 5, nil},
+		{"past the end", nil, "z", "", 0, ErrAddressOutOfRange},
+		{"zero window size", []string{"1"}, "z0", "", 0, ErrInvalidCommand},
+		{"numbered", nil, "1zn", "1\talpha,2\tbeta", 2, nil},
+		{"listed", nil, "2z1l", "beta$", 2, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, "", "alpha", "beta", "gamma", "delta", "epsilon")
+			e.SetWindowSize(2)
+			for _, line := range test.setup {
+				if _, err := e.Execute(line); err != nil {
+					t.Fatalf("Execute(%q) failed: %s", line, err)
+				}
+			}
+
+			res, err := e.Execute(test.line)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Execute(%q) gave %v, want %v", test.line, err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			got := strings.Replace(strings.TrimSuffix(res.Output, "\n"), "\n", ",", -1)
+			if got != test.want || res.Index != test.index {
+				t.Errorf("Execute(%q) printed %q leaving line %d current, want %q and %d", test.line, got, res.Index, test.want, test.index)
+			}
+		})
+	}
+}
@@ -0,0 +1,1056 @@
+package ed
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func cmdAppend(e Editor, buf Buffer, cmd Command) error {
+	if err := buf.Move(cmd.Addr()); err != nil {
+		return err
+	}
+	e.SetMode(ModeAppend)
+	return nil
+}
+
+// cmdBuffer lists the buffers, or given a buffer number makes that buffer the
+// current buffer. The list marks the current buffer with * and buffers with
+// unsaved changes with +.
+func cmdBuffer(e Editor, buf Buffer, cmd Command) error {
+	if arg := cmd.Arg(0); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return ErrInvalidBuffer
+		}
+		return e.SwitchBuffer(n)
+	}
+
+	for i, b := range e.Buffers() {
+		current, modified := ' ', ' '
+		if b == buf {
+			current = '*'
+		}
+		if b.Modified() {
+			modified = '+'
+		}
+		fmt.Fprintf(e, "%d%c%c %s\t%d\n", (i + 1), current, modified, b.Filename(), b.Size())
+	}
+
+	return nil
+}
+
+func cmdChange(e Editor, buf Buffer, cmd Command) error {
+	name, err := parseRegister(cmd)
+	if err != nil {
+		return err
+	}
+	storeLines(e, name, buf.Select(cmd.Addr()), true)
+
+	start := cmd.Addr().Start()
+	buf.Delete(cmd.Addr())
+
+	// Text is appended after the line before the deleted lines
+	if err := buf.Move(NewAddress(start-1, start-1)); err != nil {
+		return err
+	}
+	e.SetMode(ModeAppend)
+
+	return nil
+}
+
+// cmdCloseBuffer closes the given buffer, the current buffer by default,
+// warning first if it has unsaved changes
+func cmdCloseBuffer(e Editor, buf Buffer, cmd Command) error {
+	n := 0
+	for i, b := range e.Buffers() {
+		if b == buf {
+			n = i + 1
+		}
+	}
+
+	if arg := cmd.Arg(0); arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil {
+			return ErrInvalidBuffer
+		}
+	}
+
+	if n < 1 || n > len(e.Buffers()) {
+		return ErrInvalidBuffer
+	}
+	if err := checkModified(e, e.Buffers()[(n-1)]); err != nil {
+		return err
+	}
+
+	return e.CloseBuffer(n)
+}
+
+func cmdDelete(e Editor, buf Buffer, cmd Command) error {
+	name, err := parseRegister(cmd)
+	if err != nil {
+		return err
+	}
+	storeLines(e, name, buf.Select(cmd.Addr()), true)
+
+	buf.Delete(cmd.Addr())
+	return nil
+}
+
+func cmdEdit(e Editor, buf Buffer, cmd Command) error {
+	if err := checkModified(e, buf); err != nil {
+		return err
+	}
+	return cmdEditUnconditionally(e, buf, cmd)
+}
+
+func cmdEditUnconditionally(e Editor, buf Buffer, cmd Command) error {
+	if filename := cmd.Arg(0); filename != "" && !strings.HasPrefix(filename, "!") {
+		e.SetFilename(filename)
+	}
+
+	// Reading the file isn't a change to recover, nor is it recorded in any
+	// journal another session left beside it. The buffer's journal of its
+	// previous file is discarded.
+	j := buf.Journal()
+	buf.SetJournal(nil)
+	buf.Clear()
+	err := cmdRead(e, buf, cmd)
+	buf.SetJournal(j)
+	resetJournal(buf)
+	if err != nil {
+		return err
+	}
+
+	buf.SetModified(false)
+	return nil
+}
+
+func cmdFile(e Editor, buf Buffer, cmd Command) error {
+	e.SetFilename(cmd.Arg(0))
+	if e.Filename() != "" {
+		fmt.Fprintln(e, e.Filename())
+	}
+	return nil
+}
+
+func cmdGlobal(e Editor, buf Buffer, cmd Command) error {
+	return global(e, buf, cmd, false)
+}
+
+func cmdGlobalInvert(e Editor, buf Buffer, cmd Command) error {
+	return global(e, buf, cmd, true)
+}
+
+// global implements the g/re/command-list and v/re/command-list commands.
+// Matching lines are first all marked and then the command list is executed
+// with each marked line in turn as the current line. Lines deleted by the
+// command list before they are reached are skipped.
+func global(e Editor, buf Buffer, cmd Command, invert bool) error {
+	re, rest, err := parseGlobal(e, cmd)
+	if err != nil {
+		return err
+	}
+
+	cmdlist, err := readCommandList(e, rest)
+	if err != nil {
+		return err
+	}
+
+	if err := buf.Global(cmd.Addr(), re, invert); err != nil {
+		return err
+	}
+	defer buf.ClearGlobal()
+
+	for n := buf.NextGlobal(); n > 0; n = buf.NextGlobal() {
+		if e.Context().Err() != nil {
+			return ErrInterrupt
+		}
+		if err := buf.Move(&address{_start: n, _end: n}); err != nil {
+			return err
+		}
+
+		// A substitution not matching some of the lines is not an error
+		err := e.Exec(cmdlist)
+		if err != nil && !errors.Is(err, ErrNoMatch) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cmdGlobalInteractive(e Editor, buf Buffer, cmd Command) error {
+	return globalInteractive(e, buf, cmd, false)
+}
+
+func cmdGlobalInteractiveInvert(e Editor, buf Buffer, cmd Command) error {
+	return globalInteractive(e, buf, cmd, true)
+}
+
+// globalInteractive implements the G/re/ and V/re/ commands. Each marked line
+// is made the current line and printed and then a command list is read from
+// the input to execute on it. An empty line does nothing and a single & repeats
+// the previous command list.
+func globalInteractive(e Editor, buf Buffer, cmd Command, invert bool) error {
+	re, rest, err := parseGlobal(e, cmd)
+	if err != nil {
+		return err
+	}
+
+	if rest != "" {
+		return ErrInvalidCommand
+	}
+
+	if err := buf.Global(cmd.Addr(), re, invert); err != nil {
+		return err
+	}
+	defer buf.ClearGlobal()
+
+	var prev []string
+
+	for n := buf.NextGlobal(); n > 0; n = buf.NextGlobal() {
+		if e.Context().Err() != nil {
+			return ErrInterrupt
+		}
+		if err := buf.Move(&address{_start: n, _end: n}); err != nil {
+			return err
+		}
+
+		if err := printCurrent(e, buf, "p"); err != nil {
+			return err
+		}
+
+		line, err := e.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		var cmdlist []string
+
+		switch line {
+		case "":
+			continue
+		case "&":
+			if prev == nil {
+				return ErrNoPreviousCommand
+			}
+			cmdlist = prev
+		default:
+			cmdlist, err = readCommandList(e, line)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = e.Exec(cmdlist)
+		if err != nil && !errors.Is(err, ErrNoMatch) {
+			return err
+		}
+
+		prev = cmdlist
+	}
+
+	return nil
+}
+
+// parseGlobal parses the /re/ of a global command returning the regular
+// expression, or the previous one if empty, and the rest of the command
+func parseGlobal(e Editor, cmd Command) (re *regexp.Regexp, rest string, err error) {
+	args := cmd.Args()
+	if args == "" || args[0] == ' ' || args[0] == '\\' {
+		err = ErrInvalidDelimiter
+		return
+	}
+
+	expr, rest, ok := splitPattern(args[1:], args[0])
+	if !ok {
+		err = ErrInvalidDelimiter
+		return
+	}
+
+	if expr == "" {
+		re = e.Regexp()
+	} else {
+		re, err = regexp.Compile(expr)
+		if err != nil {
+			log.Debugf("error parsing expression: %s", err)
+			return
+		}
+		e.SetRegexp(re)
+	}
+
+	if re == nil {
+		log.Debug("error no search expression specified or previously set")
+		err = ErrNoExpressionSpecified
+	}
+
+	return
+}
+
+// readCommandList returns the command list of a global command starting with
+// first and continued over subsequent lines of input for as long as each line
+// ends with a backslash. An empty command list is equivalent to p.
+func readCommandList(e Editor, first string) ([]string, error) {
+	cmdlist := []string{first}
+	for strings.HasSuffix(cmdlist[len(cmdlist)-1], `\`) {
+		last := len(cmdlist) - 1
+		cmdlist[last] = strings.TrimSuffix(cmdlist[last], `\`)
+
+		line, err := e.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		cmdlist = append(cmdlist, line)
+	}
+
+	if len(cmdlist) == 1 && cmdlist[0] == "" {
+		cmdlist[0] = "p"
+	}
+
+	return cmdlist, nil
+}
+
+func cmdIndex(e Editor, buf Buffer, cmd Command) error {
+	n := cmd.Addr().End()
+	if cmd.Addr().IsUnspecified() {
+		n = buf.Size()
+	}
+	fmt.Fprintf(e, "%d\n", n)
+	return nil
+}
+
+func cmdInsert(e Editor, buf Buffer, cmd Command) error {
+	// Text is appended after the line before the addressed line, 0i is
+	// the same as 1i
+	n := cmd.Addr().End() - 1
+	if n < 0 {
+		n = 0
+	}
+
+	if err := buf.Move(NewAddress(n, n)); err != nil {
+		return err
+	}
+	e.SetMode(ModeAppend)
+
+	return nil
+}
+
+func cmdJoin(e Editor, buf Buffer, cmd Command) error {
+	name, err := parseRegister(cmd)
+	if err != nil {
+		return err
+	}
+
+	var addr Address = cmd.Addr()
+	if addr.IsUnspecified() {
+		addr = NewAddress(buf.Index(), buf.Index()+1)
+	}
+
+	if addr.End() > buf.Size() {
+		return ErrAddressOutOfRange
+	}
+	if addr.Start() == addr.End() {
+		return nil
+	}
+
+	lines := buf.Select(addr)
+	storeLines(e, name, lines, true)
+	buf.Delete(addr)
+	if err := buf.Move(NewAddress(addr.Start()-1, addr.Start()-1)); err != nil {
+		return err
+	}
+	buf.Append(strings.Join(lines, ""))
+
+	return nil
+}
+
+// cmdList prints the addressed lines unambiguously, see listLine, numbered
+// if given the n suffix
+func cmdList(e Editor, buf Buffer, cmd Command) error {
+	return printLines(e, buf, cmd.Addr(), ("l" + cmd.Print()))
+}
+
+// listLines prints the addressed lines in the form of the l command, each
+// preceded by its line number if numbered is true, and makes the last the
+// current line
+func listLines(e Editor, buf Buffer, addr Address, numbered bool) error {
+	for i, line := range buf.Select(addr) {
+		if numbered {
+			fmt.Fprintf(e, "%d\t", addr.Start()+i)
+		}
+		fmt.Fprintln(e, listLine(line, e.ListWidth()))
+	}
+	return buf.Move(addr)
+}
+
+func cmdMark(e Editor, buf Buffer, cmd Command) error {
+	name := cmd.Args()
+	if len(name) != 1 {
+		return ErrInvalidMark
+	}
+	return buf.SetMark(cmd.Addr(), name[0])
+}
+
+func cmdMove(e Editor, buf Buffer, cmd Command) error {
+	err := buf.Move(cmd.Addr())
+	if err != nil {
+		log.Debugf("error moving to line %d: %s", cmd.Addr().Start(), err)
+		return err
+	}
+	fmt.Fprintln(e, buf.Current())
+
+	return nil
+}
+
+func cmdRelocate(e Editor, buf Buffer, cmd Command) error {
+	dest, err := parseDestination(e, buf, cmd)
+	if err != nil {
+		return err
+	}
+	return buf.Relocate(cmd.Addr(), dest)
+}
+
+func cmdNumber(e Editor, buf Buffer, cmd Command) error {
+	return printLines(e, buf, cmd.Addr(), ("n" + cmd.Print()))
+}
+
+// numberLines prints the addressed lines each preceded by its line number
+// and makes the last the current line
+func numberLines(e Editor, buf Buffer, addr Address) error {
+	selection := buf.Select(addr)
+
+	// Scripts get plain output without syntax highlighting
+	if !e.Interactive() {
+		for i, line := range selection {
+			fmt.Fprintf(e, "%d\t%s\n", addr.Start()+i, line)
+		}
+		return buf.Move(addr)
+	}
+
+	out := &bytes.Buffer{}
+	source := strings.Join(selection, "\n") + "\n"
+
+	err := highlightSource(out, e.Filename(), source, "terminal16m", "vim")
+	if err != nil {
+		log.WithError(err).Debug("error syntax highlighting selection")
+		return err
+	}
+
+	// The highlighted source can end with a line of escape sequences alone
+	// which isn't one of the lines selected, the attributes are reset after
+	// the lines instead
+	ln := addr.Start()
+	scanner := bufio.NewScanner(bytes.NewBuffer(out.Bytes()))
+	for ln <= addr.End() && scanner.Scan() {
+		if ln == buf.Index() {
+			fmt.Fprintf(e, "\033[1;32m%4d\033[0m*  %s\n", ln, scanner.Text())
+		} else {
+			fmt.Fprintf(e, "\033[1;32m%4d\033[0m  %s\n", ln, scanner.Text())
+		}
+		ln++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Debugf("error printing lines: %s", err)
+		return err
+	}
+	fmt.Fprint(e, "\033[0m")
+
+	return buf.Move(addr)
+}
+
+// cmdOpen opens a new buffer editing the given file, or an empty buffer, and
+// makes it the current buffer
+func cmdOpen(e Editor, buf Buffer, cmd Command) error {
+	buf = e.OpenBuffer()
+	if cmd.Arg(0) == "" {
+		return nil
+	}
+	return cmdEditUnconditionally(e, buf, cmd)
+}
+
+func cmdPrint(e Editor, buf Buffer, cmd Command) error {
+	return printLines(e, buf, cmd.Addr(), ("p" + cmd.Print()))
+}
+
+// printLines prints the addressed lines in the format given by the print
+// command and suffixes in flags, listed if it includes l, numbered if it
+// includes n and as they are otherwise, and makes the last the current line
+func printLines(e Editor, buf Buffer, addr Address, flags string) error {
+	numbered := strings.Contains(flags, "n")
+	if strings.Contains(flags, "l") {
+		return listLines(e, buf, addr, numbered)
+	}
+	if numbered {
+		return numberLines(e, buf, addr)
+	}
+
+	selection := buf.Select(addr)
+
+	// Scripts get plain output without syntax highlighting
+	if !e.Interactive() {
+		for _, line := range selection {
+			fmt.Fprintln(e, line)
+		}
+		return buf.Move(addr)
+	}
+
+	source := strings.Join(selection, "\n") + "\n"
+
+	err := highlightSource(e, e.Filename(), source, "terminal16m", "vim")
+	if err != nil {
+		log.WithError(err).Debug("error syntax highlighting selection")
+		return err
+	}
+
+	return buf.Move(addr)
+}
+
+func cmdPut(e Editor, buf Buffer, cmd Command) error {
+	name, err := parseRegister(cmd)
+	if err != nil {
+		return err
+	}
+
+	// A failure to paste is reported once the clipboard has been put instead
+	var (
+		lines    []string
+		pasteErr error
+	)
+	if name == 0 || name == '"' {
+		lines, pasteErr = e.Paste()
+	} else {
+		lines = e.Register(name)
+	}
+
+	if err := buf.Move(cmd.Addr()); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		buf.Append(line)
+	}
+	return pasteErr
+}
+
+func cmdQuit(e Editor, buf Buffer, cmd Command) error {
+	if err := checkModified(e, e.Buffers()...); err != nil {
+		return err
+	}
+	return cmdQuitUnconditionally(e, buf, cmd)
+}
+
+func cmdQuitUnconditionally(e Editor, buf Buffer, cmd Command) error {
+	e.Stop()
+	return nil
+}
+
+func cmdRead(e Editor, buf Buffer, cmd Command) error {
+	filename := cmd.Arg(0)
+	if args := strings.TrimSpace(cmd.Args()); strings.HasPrefix(args, "!") {
+		filename = args
+	}
+	if filename == "" {
+		filename = e.Filename()
+	}
+
+	if filename == "" {
+		err := ErrNoFileSpecified
+		log.WithError(err).Debug("error must specify a filename or set a default filename")
+		return err
+	}
+
+	if e.Filename() == "" && !strings.HasPrefix(filename, "!") {
+		e.SetFilename(filename)
+	}
+
+	// Text is read after the addressed line, the end of the buffer by default
+	addr := cmd.Addr()
+	if addr.IsUnspecified() {
+		addr = NewAddress(buf.Size(), buf.Size())
+	}
+	if err := buf.Move(addr); err != nil {
+		return err
+	}
+
+	var (
+		r   io.ReadCloser
+		err error
+	)
+
+	if strings.HasPrefix(filename, "!") {
+		command, err := expandShell(e, filename[1:])
+		if err != nil {
+			return err
+		}
+		r, err = execShell(e.Context(), "", command, nil, nil, e.Errors())
+		if err != nil {
+			log.Debugf("error running shell command %s: %s", command, err)
+			return err
+		}
+	} else {
+		r, err = os.Open(filename)
+		if err != nil {
+			log.Debugf("error opening file for reading: %s", err)
+			return err
+		}
+	}
+	defer r.Close()
+
+	n, err := io.Copy(buf, r)
+
+	if err != nil {
+		log.Debugf("error reading from input file: %s", err)
+		return err
+	}
+
+	if e.Interactive() {
+		fmt.Fprintf(e, "%d\n", n)
+	}
+
+	return nil
+}
+
+func cmdShell(e Editor, buf Buffer, cmd Command) error {
+	command, err := expandShell(e, strings.TrimSpace(cmd.Args()))
+	if err != nil {
+		return err
+	}
+
+	if !cmd.Addr().IsUnspecified() {
+		return filter(e, buf, cmd.Addr(), command)
+	}
+
+	// Output is shown as the command runs
+	if _, err := execShell(e.Context(), "", command, nil, e, e.Errors()); err != nil {
+		log.Debugf("error executing command %s: %s", command, err)
+		return err
+	}
+
+	if e.Interactive() {
+		fmt.Fprintln(e, "!")
+	}
+
+	return nil
+}
+
+// expandShell expands a shell command as given to !, r !, w ! or addr! and
+// records it as the last shell command. A leading ! is replaced by the last
+// shell command and unescaped % characters by the default filename. The
+// command is echoed if it was expanded.
+func expandShell(e Editor, command string) (string, error) {
+	var (
+		sb       strings.Builder
+		expanded bool
+	)
+
+	if strings.HasPrefix(command, "!") {
+		if e.ShellCommand() == "" {
+			return "", ErrNoPreviousCommand
+		}
+		sb.WriteString(e.ShellCommand())
+		command, expanded = command[1:], true
+	}
+
+	for i := 0; i < len(command); i++ {
+		switch {
+		case command[i] == '\\' && i+1 < len(command) && command[i+1] == '%':
+			sb.WriteByte('%')
+			i++
+		case command[i] == '%':
+			if e.Filename() == "" {
+				return "", ErrNoFileSpecified
+			}
+			sb.WriteString(e.Filename())
+			expanded = true
+		default:
+			sb.WriteByte(command[i])
+		}
+	}
+
+	command = sb.String()
+	if command == "" {
+		log.Debug("error no command specified")
+		return "", ErrNoCommandSpecified
+	}
+
+	e.SetShellCommand(command)
+	if expanded && e.Interactive() {
+		fmt.Fprintln(e, command)
+	}
+
+	return command, nil
+}
+
+// filter implements addr!command replacing the addressed lines with the
+// output of command given them as input. The command's standard error is
+// written to the editor's errors and the lines are left alone if it fails.
+// The last line of output becomes the current line.
+func filter(e Editor, buf Buffer, addr Address, command string) error {
+	if addr.Start() < 1 {
+		return ErrAddressOutOfRange
+	}
+
+	var input bytes.Buffer
+	for _, line := range buf.Select(addr) {
+		input.WriteString(line)
+		input.WriteString("\n")
+	}
+
+	res, err := execShell(e.Context(), "", command, &input, nil, e.Errors())
+	if err != nil {
+		log.Debugf("error executing command %s: %s", command, err)
+		return err
+	}
+
+	start := addr.Start()
+	buf.Delete(addr)
+	if err := buf.Move(NewAddress(start-1, start-1)); err != nil {
+		return err
+	}
+
+	output := strings.TrimSuffix(string(res.Output), "\n")
+	if len(res.Output) > 0 {
+		for _, line := range strings.Split(output, "\n") {
+			buf.Append(line)
+		}
+	}
+
+	if e.Interactive() {
+		fmt.Fprintf(e, "%d\n", len(res.Output))
+	}
+
+	return nil
+}
+
+func cmdSubstitute(e Editor, buf Buffer, cmd Command) error {
+	sub, err := parseSubstitution(cmd.Args())
+	if err != nil {
+		log.Debugf("error parsing substitution: %s", err)
+		return err
+	}
+
+	var re *regexp.Regexp
+	if sub.expr == "" {
+		re = e.Regexp()
+		if re == nil {
+			log.Debug("error no search expression specified or previously set")
+			return ErrNoExpressionSpecified
+		}
+	} else {
+		re, err = regexp.Compile(sub.expr)
+		if err != nil {
+			log.Debugf("error parsing expression: %s", err)
+			return err
+		}
+		e.SetRegexp(re)
+	}
+
+	repl := sub.repl
+	if repl == "%" {
+		prev, ok := e.Replacement()
+		if !ok {
+			log.Debug("error no previous replacement")
+			return ErrNoPreviousReplacement
+		}
+		repl = prev
+	}
+
+	if err := checkBackReferences(re, repl); err != nil {
+		log.Debugf("error parsing replacement: %s", err)
+		return err
+	}
+	e.SetReplacement(repl)
+
+	if err := buf.Substitute(cmd.Addr(), re, repl, sub.nth, sub.global); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdTransfer(e Editor, buf Buffer, cmd Command) error {
+	dest, err := parseDestination(e, buf, cmd)
+	if err != nil {
+		return err
+	}
+	return buf.Transfer(cmd.Addr(), dest)
+}
+
+func cmdUndo(e Editor, buf Buffer, cmd Command) error {
+	return buf.Undo()
+}
+
+func cmdRedo(e Editor, buf Buffer, cmd Command) error {
+	return buf.Redo()
+}
+
+func cmdWrite(e Editor, buf Buffer, cmd Command) error {
+	return write(e, buf, cmd, os.O_TRUNC)
+}
+
+func cmdWriteAppend(e Editor, buf Buffer, cmd Command) error {
+	return write(e, buf, cmd, os.O_APPEND)
+}
+
+// write implements the w and W commands writing the addressed lines, the
+// whole buffer by default, to a file truncating or appending to it according
+// to flag or to the standard input of a shell command given as !command
+func write(e Editor, buf Buffer, cmd Command, flag int) error {
+	addr := cmd.Addr()
+	if addr.IsUnspecified() {
+		addr = NewAddress(1, buf.Size())
+	} else if addr.Start() < 1 {
+		return ErrAddressOutOfRange
+	}
+	whole := addr.Start() == 1 && addr.End() == buf.Size()
+
+	var data bytes.Buffer
+	if buf.Size() > 0 {
+		if _, err := buf.WriteRange(&data, addr); err != nil {
+			return err
+		}
+	}
+	n := data.Len()
+
+	// A line read without a line ending must be given one if it's no longer
+	// the last line
+	if u := buf.Unterminated(); u >= addr.Start() && u < addr.End() && e.Interactive() {
+		fmt.Fprintln(e, "newline appended")
+	}
+
+	if args := strings.TrimSpace(cmd.Args()); strings.HasPrefix(args, "!") {
+		command, err := expandShell(e, args[1:])
+		if err != nil {
+			return err
+		}
+
+		if _, err := execShell(e.Context(), "", command, &data, e, e.Errors()); err != nil {
+			log.Debugf("error executing command %s: %s", command, err)
+			return err
+		}
+
+		if e.Interactive() {
+			fmt.Fprintf(e, "%d\n", n)
+		}
+
+		return nil
+	}
+
+	filename := cmd.Arg(0)
+	if filename == "" {
+		filename = e.Filename()
+	}
+
+	if filename == "" {
+		err := ErrNoFileSpecified
+		log.WithError(err).Debug("error must specify a filename or set a default filename")
+		return err
+	}
+
+	if e.Filename() == "" {
+		e.SetFilename(filename)
+	}
+
+	// A file mapped by a rope buffer may be overwritten in place, see
+	// writeFile, which its lines mustn't see
+	for _, b := range e.Buffers() {
+		if b, ok := b.(*buffer); ok {
+			b.release(filename)
+		}
+	}
+
+	if err := writeFile(filename, data.Bytes(), flag, e.Backup()); err != nil {
+		log.Debugf("error writing to output file: %s", err)
+		return err
+	}
+	// Writing the whole buffer to any file leaves it unmodified, but the
+	// journal of its changes is kept until its own file holds them
+	if whole && flag&os.O_APPEND == 0 {
+		buf.SetModified(false)
+		if filepath.Clean(filename) == filepath.Clean(buf.Filename()) {
+			resetJournal(buf)
+		}
+	}
+
+	if e.Interactive() {
+		fmt.Fprintf(e, "%d\n", n)
+	}
+
+	return nil
+}
+
+func cmdWriteQuit(e Editor, buf Buffer, cmd Command) error {
+	if err := cmdWrite(e, buf, cmd); err != nil {
+		return err
+	}
+	return cmdQuit(e, buf, cmd)
+}
+
+func cmdYank(e Editor, buf Buffer, cmd Command) error {
+	name, err := parseRegister(cmd)
+	if err != nil {
+		return err
+	}
+	return storeLines(e, name, buf.Select(cmd.Addr()), false)
+}
+
+// cmdScroll prints a window of lines starting at the addressed line, the line
+// after the current line by default, in the format given by its suffixes. A
+// number following z sets the window size first.
+func cmdScroll(e Editor, buf Buffer, cmd Command) error {
+	if arg := cmd.Args(); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return ErrInvalidCommand
+		}
+		e.SetWindowSize(n)
+	}
+
+	start := cmd.Addr().End()
+	if cmd.Addr().IsUnspecified() {
+		start = buf.Index() + 1
+	}
+	if start < 1 || start > buf.Size() {
+		return ErrAddressOutOfRange
+	}
+
+	end := min(start+e.WindowSize()-1, buf.Size())
+	return printLines(e, buf, NewAddress(start, end), ("p" + cmd.Print()))
+}
+
+// cmdRegisters lists the lines held in the given registers, or in all the
+// registers that aren't empty, each line prefixed with its register's name
+func cmdRegisters(e Editor, buf Buffer, cmd Command) error {
+	names := strings.TrimSpace(cmd.Args())
+	if names == "" {
+		names = RegisterNames
+	}
+
+	for _, name := range names {
+		if !isRegister(name) {
+			return ErrInvalidRegister
+		}
+	}
+
+	for _, name := range names {
+		lines := e.Register(name)
+		if name == '"' {
+			var err error
+			if lines, err = e.Paste(); err != nil {
+				return err
+			}
+		}
+		for _, line := range lines {
+			fmt.Fprintf(e, "\"%c\t%s\n", unicode.ToLower(name), line)
+		}
+	}
+
+	return nil
+}
+
+// isRegister returns true if name is one of RegisterNames, or an uppercase
+// letter which names the same register as its lowercase letter
+func isRegister(name rune) bool {
+	return strings.ContainsRune(RegisterNames, unicode.ToLower(name))
+}
+
+// parseRegister returns the name of the register given as "x following the
+// y, x, d, c and j commands, or 0 if there is none
+func parseRegister(cmd Command) (rune, error) {
+	arg := strings.TrimSpace(cmd.Args())
+	if !strings.HasPrefix(arg, `"`) {
+		return 0, nil
+	}
+
+	name, size := utf8.DecodeRuneInString(arg[1:])
+	if size == 0 || (1+size) != len(arg) || !isRegister(name) {
+		return 0, ErrInvalidRegister
+	}
+	return name, nil
+}
+
+// storeLines stores lines yanked or deleted by a command in the named
+// register, or if there is none yanked lines in register 0 and deleted lines
+// in register 1, shifting earlier deletions along to register 9. The lines
+// stored also become the clipboard, which x puts by default, and yanked lines
+// are copied to the clipboard provider if there is one.
+func storeLines(e Editor, name rune, lines []string, deleted bool) error {
+	switch {
+	case name == '"':
+	case name != 0:
+		e.SetRegister(name, lines)
+		lines = e.Register(name)
+	case deleted:
+		for r := '9'; r > '1'; r-- {
+			e.SetRegister(r, e.Register(r-1))
+		}
+		e.SetRegister('1', lines)
+	default:
+		e.SetRegister('0', lines)
+	}
+
+	if deleted {
+		e.SetClipboard(lines)
+		return nil
+	}
+	return e.Copy(lines)
+}
+
+// checkModified returns ErrBufferModified if any of the buffers have unsaved
+// changes that a command would discard, unless the user has just been warned
+func checkModified(e Editor, bufs ...Buffer) error {
+	if e.Warned() {
+		return nil
+	}
+	for _, buf := range bufs {
+		if buf.Modified() {
+			return ErrBufferModified
+		}
+	}
+	return nil
+}
+
+// parseDestination resolves the destination address following the m and t
+// commands, the address 0 is valid and is the beginning of the buffer
+func parseDestination(e Editor, buf Buffer, cmd Command) (int, error) {
+	// The destination is all that's left of the arguments once the print
+	// suffixes have been removed, see ParseCommand
+	addr, _, err := parseAddress(cmd.Args())
+	if err != nil {
+		return 0, err
+	}
+
+	err = addr.Resolve(buf, e.Regexp())
+	if re := addr.Regexp(); re != nil {
+		e.SetRegexp(re)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if addr.IsUnspecified() {
+		return 0, ErrNoDestination
+	}
+
+	return addr.End(), nil
+}
+
+// printCurrent prints the current line in the format of the given print
+// suffixes, any of p (print), n (numbered) and l (list)
+func printCurrent(e Editor, buf Buffer, suffixes string) error {
+	if buf.Index() < 1 {
+		return ErrAddressOutOfRange
+	}
+	return printLines(e, buf, NewAddress(buf.Index(), buf.Index()), suffixes)
+}
@@ -0,0 +1,767 @@
+// Package ed implements the ed line editor as a library: a line Buffer, a
+// parser for ed commands and their addresses, and an Editor that dispatches
+// commands to a registry of Handlers. The ed command is a thin front-end.
+package ed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Editor ...
+type Editor interface {
+	io.Writer
+	io.ReaderFrom
+
+	Errors() io.Writer
+	Stop()
+	Run() error
+	Execute(line string) (*Result, error)
+	Interactive() bool
+	Warned() bool
+	Context() context.Context
+	Backup() int
+	Exec(cmdlist []string) error
+	ReadLine() (string, error)
+	Buffer() Buffer
+	Buffers() []Buffer
+	OpenBuffer() Buffer
+	SwitchBuffer(n int) error
+	CloseBuffer(n int) error
+	Regexp() *regexp.Regexp
+	SetRegexp(re *regexp.Regexp)
+	Replacement() (string, bool)
+	SetReplacement(repl string)
+	ShellCommand() string
+	SetShellCommand(command string)
+	Clipboard() []string
+	SetClipboard(lines []string)
+	Copy(lines []string) error
+	Paste() ([]string, error)
+	Register(name rune) []string
+	SetRegister(name rune, lines []string)
+	Filename() string
+	SetFilename(filename string)
+	SetMode(mode int)
+	SetPrompt(prompt string)
+	ListWidth() int
+	SetListWidth(width int)
+	WindowSize() int
+	SetWindowSize(size int)
+	Handle(cmd string, handler Handler)
+}
+
+// Result describes the outcome of a line processed by Execute
+type Result struct {
+	// Command is the parsed command, nil if the line was text in input mode
+	Command Command
+
+	// Output is what the command printed, it's also written to the editor's
+	// output as usual
+	Output string
+
+	// Index and Size are the buffer's current line and number of lines
+	Index int
+	Size  int
+
+	// Mode is the editor's mode, ModeAppend if the command is waiting for
+	// text to be given to subsequent calls
+	Mode int
+
+	// Stopped is true if the command quit the editor
+	Stopped bool
+}
+
+// lineReader is a source of lines for the editor to process, the readline
+// instance at the top-level or a command list being executed
+type lineReader interface {
+	Readline() (string, error)
+}
+
+// script is a lineReader over a fixed list of lines
+type script struct {
+	lines []string
+}
+
+func (s *script) Readline() (string, error) {
+	if len(s.lines) == 0 {
+		return "", io.EOF
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+// plainReader is a lineReader over a plain io.Reader used in script mode
+type plainReader struct {
+	r *bufio.Reader
+}
+
+func (p *plainReader) Readline() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// Terminal is an interactive front-end for the editor such as readline. The
+// prompt is shown before reading each command and the Readline method returns
+// ErrInterrupt when the user interrupts input. A Terminal that also has the
+// Size method of terminalSizer sets the default window size of z.
+type Terminal interface {
+	Readline() (string, error)
+	SetPrompt(prompt string)
+	Close() error
+}
+
+// terminalSizer is a Terminal that knows the size of the terminal
+type terminalSizer interface {
+	Size() (width, height int, err error)
+}
+
+// defaultWindowSize is the number of lines z prints if it isn't set and the
+// terminal's height isn't known
+const defaultWindowSize = 22
+
+// Option configures an editor when it's created with NewEditor
+type Option func(e *editor) error
+
+// WithInput makes the editor read commands from r, as in script mode. No
+// prompt is shown, nothing is syntax highlighted and Run returns an error if
+// any command failed. This is the default with os.Stdin.
+func WithInput(r io.Reader) Option {
+	return func(e *editor) error {
+		e.in, e.term = &plainReader{bufio.NewReader(r)}, nil
+		return nil
+	}
+}
+
+// WithTerminal makes the editor read commands interactively from term
+func WithTerminal(term Terminal) Option {
+	return func(e *editor) error {
+		e.in, e.term = term, term
+		return nil
+	}
+}
+
+// WithContext sets the context shell commands are run with, cancelling it
+// kills any that are running. Run interrupts each command on SIGINT.
+func WithContext(ctx context.Context) Option {
+	return func(e *editor) error {
+		e.ctx = ctx
+		return nil
+	}
+}
+
+// WithBuffers makes the editor create its buffers with newBuffer, e.g.
+// NewRopeBuffer for large files, rather than the default NewBuffer
+func WithBuffers(newBuffer func() Buffer) Option {
+	return func(e *editor) error {
+		e.newBuffer = newBuffer
+		return nil
+	}
+}
+
+// WithBackup makes the editor keep a copy of a file before overwriting it
+// named according to style, BackupSimple or BackupTimestamp
+func WithBackup(style int) Option {
+	return func(e *editor) error {
+		e.backup = style
+		return nil
+	}
+}
+
+// WithClipboard makes the editor keep the clipboard, which y and x use by
+// default, with the provider c e.g. the system clipboard
+func WithClipboard(c Clipboard) Option {
+	return func(e *editor) error {
+		e.provider = c
+		return nil
+	}
+}
+
+// WithOutput sets where the editor writes the output of commands, os.Stdout
+// by default
+func WithOutput(w io.Writer) Option {
+	return func(e *editor) error {
+		e.output = w
+		return nil
+	}
+}
+
+// WithErrors sets where the editor reports commands that failed, os.Stderr by
+// default
+func WithErrors(w io.Writer) Option {
+	return func(e *editor) error {
+		e.errors = w
+		return nil
+	}
+}
+
+type editor struct {
+	ctx         context.Context
+	term        Terminal
+	in          lineReader
+	input       []lineReader
+	output      io.Writer
+	errors      io.Writer
+	failed      bool
+	backup      int
+	warned      string
+	command     string
+	mode        int
+	prompt      string
+	listWidth   int
+	window      int
+	running     bool
+	buffer      Buffer
+	buffers     []Buffer
+	newBuffer   func() Buffer
+	clipboard   []string
+	provider    Clipboard
+	registers   map[rune][]string
+	regexp      *regexp.Regexp
+	replacement *string
+	shell       string
+	handlers    map[string]Handler
+}
+
+// NewEditor returns an editor with an empty buffer and the DefaultHandlers
+// registered, reading commands from os.Stdin unless configured otherwise
+func NewEditor(options ...Option) (Editor, error) {
+	e := &editor{
+		ctx:       context.Background(),
+		in:        &plainReader{bufio.NewReader(os.Stdin)},
+		output:    os.Stdout,
+		errors:    os.Stderr,
+		mode:      ModeCommand,
+		prompt:    "> ",
+		listWidth: 72,
+		running:   true,
+		newBuffer: NewBuffer,
+		registers: make(map[rune][]string),
+		handlers:  DefaultHandlers(),
+	}
+
+	for _, option := range options {
+		if err := option(e); err != nil {
+			return nil, err
+		}
+	}
+
+	e.buffer = e.newBuffer()
+	e.buffers = []Buffer{e.buffer}
+
+	return e, nil
+}
+
+// Write writes to the editor's output, handlers print through the editor
+func (e *editor) Write(p []byte) (n int, err error) {
+	return e.output.Write(p)
+}
+
+// Errors returns the writer errors and the diagnostics of shell commands are
+// written to, kept apart from the editor's output
+func (e *editor) Errors() io.Writer {
+	return e.errors
+}
+
+func (e *editor) ReadFrom(r io.Reader) (n int64, err error) {
+	if n, err = e.buffer.ReadFrom(r); err != nil {
+		return
+	}
+	if e.Interactive() {
+		fmt.Fprintf(e, "%d\n", n)
+	}
+	return
+}
+
+// Warned returns true if the previous command failed with ErrBufferModified,
+// repeating the same command, with the same arguments, confirms that the
+// changes are to be discarded
+func (e *editor) Warned() bool {
+	return e.warned != "" && e.warned == e.command
+}
+
+// Context returns the context of the command being executed which is done
+// if the command is interrupted
+func (e *editor) Context() context.Context {
+	return e.ctx
+}
+
+// Backup returns the style of backup made of files before they're written
+func (e *editor) Backup() int {
+	return e.backup
+}
+
+// Interactive returns true if the editor is reading commands from a terminal
+// rather than running a script
+func (e *editor) Interactive() bool {
+	return e.term != nil
+}
+
+// Buffer returns the current buffer
+func (e *editor) Buffer() Buffer {
+	return e.buffer
+}
+
+// Buffers returns the editor's buffers, the first is buffer 1
+func (e *editor) Buffers() []Buffer {
+	return e.buffers
+}
+
+// OpenBuffer adds a new empty buffer and makes it the current buffer. Its
+// changes are journaled if the current buffer's are.
+func (e *editor) OpenBuffer() Buffer {
+	buf := e.newBuffer()
+	if e.buffer.Journal() != nil {
+		buf.SetJournal(NewJournal(buf))
+	}
+
+	e.buffers = append(e.buffers, buf)
+	e.buffer = buf
+
+	return buf
+}
+
+// SwitchBuffer makes buffer n the current buffer
+func (e *editor) SwitchBuffer(n int) error {
+	if n < 1 || n > len(e.buffers) {
+		return ErrInvalidBuffer
+	}
+	e.buffer = e.buffers[(n - 1)]
+	return nil
+}
+
+// CloseBuffer removes buffer n discarding any changes, the buffer before it
+// becomes the current buffer if it was. The only buffer can't be closed.
+func (e *editor) CloseBuffer(n int) error {
+	if n < 1 || n > len(e.buffers) {
+		return ErrInvalidBuffer
+	}
+	if len(e.buffers) == 1 {
+		return ErrLastBuffer
+	}
+
+	buf := e.buffers[(n - 1)]
+	if j := buf.Journal(); j != nil {
+		j.Reset()
+	}
+	e.buffers = append(e.buffers[:(n-1)], e.buffers[n:]...)
+
+	if buf == e.buffer {
+		e.buffer = e.buffers[max(n-2, 0)]
+	}
+
+	return nil
+}
+
+func (e *editor) Regexp() *regexp.Regexp {
+	return e.regexp
+}
+
+func (e *editor) SetRegexp(re *regexp.Regexp) {
+	e.regexp = re
+}
+
+func (e *editor) Replacement() (string, bool) {
+	if e.replacement == nil {
+		return "", false
+	}
+	return *e.replacement, true
+}
+
+func (e *editor) SetReplacement(repl string) {
+	e.replacement = &repl
+}
+
+// ShellCommand returns the last shell command run, which !! repeats
+func (e *editor) ShellCommand() string {
+	return e.shell
+}
+
+func (e *editor) SetShellCommand(command string) {
+	e.shell = command
+}
+
+// Clipboard returns the lines last yanked or deleted, the editor's own copy
+// which is kept whether or not there is a clipboard provider
+func (e *editor) Clipboard() []string {
+	return e.clipboard
+}
+
+func (e *editor) SetClipboard(lines []string) {
+	e.clipboard = lines[:]
+}
+
+// Copy sets the clipboard to lines and copies them to the clipboard provider
+// if there is one, which y does but not the commands that delete lines
+func (e *editor) Copy(lines []string) error {
+	e.SetClipboard(lines)
+	if e.provider == nil {
+		return nil
+	}
+	if err := e.provider.Copy(e.ctx, lines); err != nil {
+		return fmt.Errorf("error copying to clipboard: %w", err)
+	}
+	return nil
+}
+
+// Paste returns the contents of the clipboard provider if there is one, or
+// the clipboard otherwise. The clipboard is returned with the error if the
+// provider fails.
+func (e *editor) Paste() ([]string, error) {
+	if e.provider == nil {
+		return e.Clipboard(), nil
+	}
+	lines, err := e.provider.Paste(e.ctx)
+	if err != nil {
+		return e.Clipboard(), fmt.Errorf("error pasting from clipboard: %w", err)
+	}
+	return lines, nil
+}
+
+// RegisterNames are the names of the registers in the order they're listed,
+// the unnamed register ", the numbered registers 0 to 9 and the named
+// registers a to z
+const RegisterNames = `"0123456789abcdefghijklmnopqrstuvwxyz`
+
+// Register returns the lines held in the named register, one of
+// RegisterNames. An uppercase letter names the same register as its
+// lowercase letter and the unnamed register " is the clipboard.
+func (e *editor) Register(name rune) []string {
+	name = unicode.ToLower(name)
+	if name == '"' {
+		return e.Clipboard()
+	}
+	return e.registers[name]
+}
+
+// SetRegister stores lines in the named register, or appends them to it if
+// the name is an uppercase letter. Names that aren't registers are ignored.
+func (e *editor) SetRegister(name rune, lines []string) {
+	lines = append([]string(nil), lines...)
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+		lines = append(append([]string(nil), e.Register(name)...), lines...)
+	}
+
+	switch {
+	case name == '"':
+		e.SetClipboard(lines)
+	case strings.ContainsRune(RegisterNames, name):
+		e.registers[name] = lines
+	}
+}
+
+// Filename returns the current buffer's default filename
+func (e *editor) Filename() string {
+	return e.buffer.Filename()
+}
+
+func (e *editor) SetFilename(filename string) {
+	e.buffer.SetFilename(filename)
+}
+
+func (e *editor) SetMode(mode int) {
+	e.mode = mode
+	if e.term == nil {
+		return
+	}
+	if mode == ModeCommand {
+		e.term.SetPrompt(e.prompt)
+	} else {
+		e.term.SetPrompt("")
+	}
+}
+
+func (e *editor) SetPrompt(prompt string) {
+	e.prompt = prompt
+	if e.term != nil && e.mode == ModeCommand {
+		e.term.SetPrompt(prompt)
+	}
+}
+
+// ListWidth returns the width lines printed by the l command are folded at,
+// 0 if they aren't folded
+func (e *editor) ListWidth() int {
+	return e.listWidth
+}
+
+func (e *editor) SetListWidth(width int) {
+	e.listWidth = width
+}
+
+// WindowSize returns the number of lines the z command prints, the size set
+// by SetWindowSize or if there is none the height of the terminal less a
+// line for the prompt
+func (e *editor) WindowSize() int {
+	if e.window > 0 {
+		return e.window
+	}
+	if t, ok := e.term.(terminalSizer); ok {
+		if _, height, err := t.Size(); err == nil && height > 1 {
+			return height - 1
+		}
+	}
+	return defaultWindowSize
+}
+
+// SetWindowSize sets the number of lines the z command prints, 0 to use the
+// height of the terminal
+func (e *editor) SetWindowSize(size int) {
+	e.window = size
+}
+
+func (e *editor) Handle(cmd string, handler Handler) {
+	e.handlers[cmd] = handler
+}
+
+func (e *editor) Stop() {
+	e.running = false
+}
+
+func (e *editor) Close() {
+	if e.term != nil {
+		e.term.Close()
+	}
+}
+
+// ReadLine reads the next line from the current input, which is the command
+// list being executed if called from within a global command.
+func (e *editor) ReadLine() (string, error) {
+	// Execute can be called without Run, in which case the editor's input is
+	// read directly
+	if len(e.input) == 0 {
+		return e.in.Readline()
+	}
+	return e.input[len(e.input)-1].Readline()
+}
+
+// Exec executes a command list, as used by the global commands, one command
+// per line. Lines following a command that enters input mode are taken as its
+// text up to a line containing a single period or the end of the list.
+// The first error encountered stops execution and is returned.
+func (e *editor) Exec(cmdlist []string) error {
+	mode := e.mode
+	e.input = append(e.input, &script{lines: cmdlist})
+	defer func() {
+		e.input = e.input[:len(e.input)-1]
+		e.SetMode(mode)
+	}()
+
+	e.SetMode(ModeCommand)
+	for e.running {
+		if e.ctx.Err() != nil {
+			return ErrInterrupt
+		}
+
+		line, err := e.ReadLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := e.exec(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *editor) Run() error {
+	defer e.Close()
+
+	e.input = append(e.input, e.in)
+
+	e.running = true
+	for e.running {
+		line, err := e.ReadLine()
+		if err == ErrInterrupt {
+			e.SetMode(ModeCommand)
+			continue
+		} else if err == io.EOF {
+			// The end of input is treated as q and so must be repeated to
+			// quit if the buffer has been modified, while a script quits
+			// silently as with ed -s
+			e.SetMode(ModeCommand)
+			line = "q"
+			if !e.Interactive() {
+				line = "Q"
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if err := e.interruptible(line); err != nil {
+			fmt.Fprintln(e.errors, err)
+			e.failed = true
+
+			// Interrupting a script stops it
+			if errors.Is(err, ErrInterrupt) && !e.Interactive() {
+				return err
+			}
+		}
+	}
+
+	// Quitting normally discards the journals of unsaved changes
+	for _, buf := range e.buffers {
+		if j := buf.Journal(); j != nil {
+			if err := j.Reset(); err != nil {
+				fmt.Fprintf(e.errors, "error removing journal: %s\n", err)
+			}
+		}
+	}
+
+	if e.failed && !e.Interactive() {
+		return ErrCommandFailed
+	}
+
+	return nil
+}
+
+// interruptible executes line with SIGINT, from Ctrl-C in the terminal,
+// cancelling the command's context rather than killing the editor
+func (e *editor) interruptible(line string) error {
+	parent := e.ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer func() {
+		cancel()
+		e.ctx = parent
+	}()
+	e.ctx = ctx
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Commands that don't watch the context run to completion, but an
+	// interrupt is still reported so that a script stops
+	_, err := e.Execute(line)
+	if err == nil && ctx.Err() != nil {
+		return ErrInterrupt
+	}
+	return err
+}
+
+// journalErr returns an error writing to or resetting the journal of any of
+// the buffers, which the buffers don't report themselves
+func (e *editor) journalErr() error {
+	for _, buf := range e.buffers {
+		if j := buf.Journal(); j != nil {
+			if err := j.Err(); err != nil {
+				return fmt.Errorf("error journaling changes: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Execute processes a single top-level line of input as Run does, either a
+// command or text for a preceding command in input mode, and returns the
+// result. Each command executed is a single transaction for undo.
+func (e *editor) Execute(line string) (*Result, error) {
+	if e.mode == ModeCommand {
+		e.buffer.Checkpoint()
+	}
+
+	var output bytes.Buffer
+	w := e.output
+	e.output = io.MultiWriter(w, &output)
+	defer func() {
+		e.output = w
+	}()
+
+	e.running = true
+	cmd, err := e.exec(line)
+	if err == nil {
+		err = e.journalErr()
+	}
+	e.warned = ""
+	if cmd != nil && errors.Is(err, ErrBufferModified) {
+		e.warned = cmd.String()
+	}
+
+	return &Result{
+		Command: cmd,
+		Output:  output.String(),
+		Index:   e.buffer.Index(),
+		Size:    e.buffer.Size(),
+		Mode:    e.mode,
+		Stopped: !e.running,
+	}, err
+}
+
+// exec processes a single line of input either as a command or as text to be
+// added to the buffer depending on the current mode. The parsed command is
+// returned if the line was a command.
+func (e *editor) exec(line string) (Command, error) {
+	if e.mode != ModeCommand {
+		if line == "." {
+			e.SetMode(ModeCommand)
+			return nil, nil
+		}
+
+		switch e.mode {
+		case ModeAppend:
+			e.buffer.Append(line)
+		default:
+			panic("unknown input mode")
+		}
+
+		return nil, nil
+	}
+
+	cmd, err := ParseCommand(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing command: %w", err)
+	}
+
+	err = cmd.Validate(e.buffer, e.regexp)
+	if re := cmd.Addr().Regexp(); re != nil {
+		e.SetRegexp(re)
+	}
+	if err != nil {
+		return cmd, fmt.Errorf("error validating command: %w", err)
+	}
+
+	handler, ok := e.handlers[cmd.Cmd()]
+	if !ok {
+		return cmd, fmt.Errorf("error unknown command: %s", line)
+	}
+
+	e.command = cmd.String()
+	if err := handler(e, e.buffer, cmd); err != nil {
+		return cmd, fmt.Errorf("error processing command %s: %w", cmd.String(), err)
+	}
+
+	// The print commands print lines in the format given by their suffixes
+	// themselves
+	if cmd.Print() != "" && !printCommands[cmd.Cmd()] {
+		if err := printCurrent(e, e.buffer, cmd.Print()); err != nil {
+			return cmd, fmt.Errorf("error processing command %s: %w", cmd.String(), err)
+		}
+	}
+
+	return cmd, nil
+}
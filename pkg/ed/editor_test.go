@@ -0,0 +1,187 @@
+package ed
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns an editor whose buffer holds lines, reading any
+// further input from input, and the buffer its output is written to
+func newTestEditor(t *testing.T, input string, lines ...string) (Editor, *bytes.Buffer) {
+	t.Helper()
+
+	var out bytes.Buffer
+	e, err := NewEditor(
+		WithInput(strings.NewReader(input)),
+		WithOutput(&out),
+		WithErrors(&out),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) > 0 {
+		text := strings.Join(lines, "\n") + "\n"
+		if _, err := e.Buffer().ReadFrom(strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return e, &out
+}
+
+// contents returns the lines of the editor's current buffer
+func contents(e Editor) []string {
+	buf := e.Buffer()
+	if buf.Size() == 0 {
+		return nil
+	}
+	return buf.Select(NewAddress(1, buf.Size()))
+}
+
+func TestExecuteReadsInputWithoutRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		input string
+		want  []string
+	}{
+		{"interactive global", "G/o/", "s/o/0/\n\n", []string{"f0o", "bar", "boo"}},
+		{"interactive inverse global", "V/o/", "s/a/A/\n", []string{"foo", "bAr", "boo"}},
+		{"global continued", `g/o/s/o/0/\`, "s/b/B/\n", []string{"f0o", "bar", "B0o"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, test.input, "foo", "bar", "boo")
+			if _, err := e.Execute(test.line); err != nil {
+				t.Fatalf("Execute(%q) failed: %s", test.line, err)
+			}
+			if got := contents(e); strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("Execute(%q) gave %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRunScriptQuitsAtEOF(t *testing.T) {
+	e, out := newTestEditor(t, "1d\n", "foo", "bar")
+	if err := e.Run(); err != nil {
+		t.Fatalf("Run failed: %s\n%s", err, out)
+	}
+	if got := contents(e); len(got) != 1 || got[0] != "bar" {
+		t.Errorf("Run gave %q, want [bar]", got)
+	}
+}
+
+func TestWarnedOnlyForTheSameCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		confirmed bool
+	}{
+		{"quit", []string{"q", "q"}, true},
+		{"quit after another command", []string{"q", "1p", "q"}, false},
+		{"close buffer", []string{"B 2", "B 2"}, true},
+		{"close another buffer", []string{"B 2", "B 3"}, false},
+		{"edit", []string{"e a", "e b"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, "", "foo")
+			e.Buffer().SetModified(true)
+			for i := 0; i < 2; i++ {
+				e.OpenBuffer().SetModified(true)
+			}
+			e.SwitchBuffer(1)
+
+			var err error
+			for _, line := range test.lines {
+				_, err = e.Execute(line)
+			}
+
+			if test.confirmed && errors.Is(err, ErrBufferModified) {
+				t.Errorf("%q wasn't confirmed by repeating it", test.lines)
+			}
+			if !test.confirmed && !errors.Is(err, ErrBufferModified) {
+				t.Errorf("%q gave %v, want %v", test.lines, err, ErrBufferModified)
+			}
+		})
+	}
+}
+
+func TestWriteLeavesBufferUnmodified(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		stopped bool
+	}{
+		{"write quit", []string{"wq %s"}, true},
+		{"write then quit", []string{"w %s", "q"}, true},
+		{"write part then quit", []string{"1w %s", "q"}, false},
+		{"append then quit", []string{"W %s", "q"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ed-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			e, _ := newTestEditor(t, "", "foo", "bar")
+			e.SetFilename(filepath.Join(dir, "own"))
+			e.Buffer().SetModified(true)
+
+			var res *Result
+			for _, line := range test.lines {
+				line = strings.Replace(line, "%s", filepath.Join(dir, "other"), 1)
+				if res, err = e.Execute(line); err != nil && !errors.Is(err, ErrBufferModified) {
+					t.Fatalf("Execute(%q) failed: %s", line, err)
+				}
+			}
+			if res.Stopped != test.stopped {
+				t.Errorf("%q stopped the editor is %t, want %t", test.lines, res.Stopped, test.stopped)
+			}
+		})
+	}
+}
+
+func TestShellErrorsAreKeptApart(t *testing.T) {
+	tests := []string{
+		"!echo oops >&2",
+		"1!echo oops >&2; echo new",
+		"w !echo oops >&2",
+		"r !echo oops >&2; echo new",
+	}
+
+	for _, line := range tests {
+		var out, errs bytes.Buffer
+		e, err := NewEditor(WithOutput(&out), WithErrors(&errs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Buffer().ReadFrom(strings.NewReader("foo\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := e.Execute(line)
+		if err != nil {
+			t.Errorf("Execute(%q) failed: %s", line, err)
+			continue
+		}
+		if strings.Contains(out.String(), "oops") || strings.Contains(res.Output, "oops") {
+			t.Errorf("Execute(%q) printed the command's errors %q", line, out.String())
+		}
+		if errs.String() != "oops\n" {
+			t.Errorf("Execute(%q) wrote errors %q, want %q", line, errs.String(), "oops\n")
+		}
+	}
+}
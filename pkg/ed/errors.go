@@ -0,0 +1,36 @@
+package ed
+
+import "errors"
+
+var (
+	ErrInvalidCommand        = errors.New("error: invalid command")
+	ErrAddressOutOfRange     = errors.New("error: address out of range")
+	ErrNoFileSpecified       = errors.New("error: no filename specified")
+	ErrNoExpressionSpecified = errors.New("error: no expression specified")
+	ErrNoCommandSpecified    = errors.New("error: no command specified")
+	ErrNoMatch               = errors.New("error: no match")
+	ErrNoPreviousReplacement = errors.New("error: no previous replacement")
+	ErrInvalidDelimiter      = errors.New("error: invalid pattern delimiter")
+	ErrInvalidSuffix         = errors.New("error: invalid command suffix")
+	ErrInvalidBackReference  = errors.New("error: invalid back reference")
+	ErrNestedGlobal          = errors.New("error: cannot nest global commands")
+	ErrNoPreviousCommand     = errors.New("error: no previous command")
+	ErrNothingToUndo         = errors.New("error: nothing to undo")
+	ErrNothingToRedo         = errors.New("error: nothing to redo")
+	ErrUndoInGlobal          = errors.New("error: cannot undo within a global command")
+	ErrInvalidAddress        = errors.New("error: invalid address")
+	ErrInvalidMark           = errors.New("error: invalid mark character")
+	ErrUndefinedMark         = errors.New("error: undefined mark")
+	ErrInvalidDestination    = errors.New("error: invalid destination")
+	ErrNoDestination         = errors.New("error: destination expected")
+	ErrCommandFailed         = errors.New("error: one or more commands failed")
+	ErrInterrupt             = errors.New("error: interrupted")
+	ErrBufferModified        = errors.New("warning: buffer modified")
+	ErrInvalidJournal        = errors.New("error: invalid journal")
+	ErrJournalLocked         = errors.New("error: journal in use by another session")
+	ErrJournalExists         = errors.New("error: journal of another session exists")
+	ErrJournalMismatch       = errors.New("error: file changed since the journal was started")
+	ErrInvalidBuffer         = errors.New("error: invalid buffer number")
+	ErrInvalidRegister       = errors.New("error: invalid register")
+	ErrLastBuffer            = errors.New("error: cannot close the only buffer")
+)
@@ -0,0 +1,173 @@
+package ed
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Backup styles for the copy kept of a file before it's overwritten
+const (
+	BackupNone      = iota
+	BackupSimple    // file~
+	BackupTimestamp // file.YYYYMMDD-HHMMSS~
+)
+
+// modeBits are the bits of a file's mode kept when it's replaced
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// errCantReplace is returned by replaceFile if the file can't be replaced as
+// its owner can't be kept or a temporary file can't be created beside it
+var errCantReplace = errors.New("error: cannot replace the file")
+
+// writeFile writes data to filename replacing it, or appending to it if flag
+// is os.O_APPEND, atomically if possible. A backup of the original file is
+// made first according to backup. The data is written to a temporary file in
+// the same directory which is synced and renamed over filename so a failure
+// part way through leaves the original intact. A file with other hard links,
+// whose owner can't be kept or in a directory that can't be written to, is
+// written in place instead.
+func writeFile(filename string, data []byte, flag int, backup int) error {
+	// Write through symbolic links rather than replacing them
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+
+	if exists && backup != BackupNone {
+		if err := backupFile(filename, fi, backup); err != nil {
+			return err
+		}
+	}
+
+	if exists && links(fi) > 1 {
+		return writeInPlace(filename, data, flag)
+	}
+
+	err = replaceFile(filename, fi, data, flag)
+	if err == errCantReplace {
+		return writeInPlace(filename, data, flag)
+	}
+	return err
+}
+
+// replaceFile writes data to a temporary file which replaces filename, fi
+// describes the file if it exists. errCantReplace is returned, leaving the
+// file unchanged, if the temporary file can't be created or the file's owner
+// can't be given to it.
+func replaceFile(filename string, fi os.FileInfo, data []byte, flag int) (err error) {
+	exists := fi != nil
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		// An existing file may be writable where no new file can be created
+		if exists && (os.IsPermission(err) || errors.Is(err, syscall.EROFS)) {
+			return errCantReplace
+		}
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if exists && flag&os.O_APPEND != 0 {
+		if err = copyContents(tmp, filename); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	// The owner is set first as changing it clears the setuid and setgid bits
+	if exists {
+		if chown(tmp, fi) != nil {
+			return errCantReplace
+		}
+		if err = tmp.Chmod(fi.Mode() & modeBits); err != nil {
+			return err
+		}
+	} else if err = tmp.Chmod(0666 &^ umask()); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	// The rename itself is only durable once the directory is synced
+	return syncDir(filepath.Dir(filename))
+}
+
+// writeInPlace overwrites filename with data, or appends data to it if flag
+// is os.O_APPEND, keeping the file itself along with its owner and links
+func writeInPlace(filename string, data []byte, flag int) error {
+	f, err := os.OpenFile(filename, (os.O_WRONLY | flag), 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// backupFile copies filename to its backup name according to style
+func backupFile(filename string, fi os.FileInfo, style int) error {
+	name := filename + "~"
+	if style == BackupTimestamp {
+		name = fmt.Sprintf("%s.%s~", filename, time.Now().Format("20060102-150405"))
+	}
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode()&modeBits)
+	if err != nil {
+		return err
+	}
+
+	if err := copyContents(f, filename); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// copyContents copies the contents of the file filename to w
+func copyContents(w io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,42 @@
+package ed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileInReadOnlyDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can create files in any directory")
+	}
+
+	dir, remove := tempDir(t)
+	defer remove()
+	filename := filepath.Join(dir, "a.txt")
+	writeFiles(t, dir, map[string]string{"a.txt": "foo\n"})
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	tests := []struct {
+		name string
+		flag int
+		want string
+	}{
+		{"write", os.O_TRUNC, "bar\n"},
+		{"append", os.O_APPEND, "bar\nbaz\n"},
+	}
+
+	for _, test := range tests {
+		data := []byte(test.want[(len(test.want) - 4):])
+		if err := writeFile(filename, data, test.flag, BackupNone); err != nil {
+			t.Fatalf("%s failed: %s", test.name, err)
+		}
+		if got, _ := ioutil.ReadFile(filename); string(got) != test.want {
+			t.Errorf("%s gave %q, want %q", test.name, got, test.want)
+		}
+	}
+}
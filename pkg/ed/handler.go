@@ -0,0 +1,49 @@
+package ed
+
+// Handler ...
+type Handler func(e Editor, buf Buffer, cmd Command) error
+
+// DefaultHandlers returns the handlers for the standard ed commands keyed by
+// command name, NewEditor registers these and Editor.Handle replaces or adds
+// to them
+func DefaultHandlers() map[string]Handler {
+	return map[string]Handler{
+		"":   cmdMove,
+		"!":  cmdShell,
+		"\"": cmdRegisters,
+		"=":  cmdIndex,
+		"B":  cmdCloseBuffer,
+		"E":  cmdEditUnconditionally,
+		"G":  cmdGlobalInteractive,
+		"Q":  cmdQuitUnconditionally,
+		"V":  cmdGlobalInteractiveInvert,
+		"W":  cmdWriteAppend,
+		"a":  cmdAppend,
+		"b":  cmdBuffer,
+		"c":  cmdChange,
+		"d":  cmdDelete,
+		"e":  cmdEdit,
+		"f":  cmdFile,
+		"g":  cmdGlobal,
+		"i":  cmdInsert,
+		"j":  cmdJoin,
+		"k":  cmdMark,
+		"l":  cmdList,
+		"m":  cmdRelocate,
+		"n":  cmdNumber,
+		"o":  cmdOpen,
+		"p":  cmdPrint,
+		"q":  cmdQuit,
+		"r":  cmdRead,
+		"s":  cmdSubstitute,
+		"t":  cmdTransfer,
+		"u":  cmdUndo,
+		"U":  cmdRedo,
+		"v":  cmdGlobalInvert,
+		"w":  cmdWrite,
+		"wq": cmdWriteQuit,
+		"x":  cmdPut,
+		"y":  cmdYank,
+		"z":  cmdScroll,
+	}
+}
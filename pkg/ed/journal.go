@@ -0,0 +1,316 @@
+package ed
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Journal receives a record of every change made to a buffer since it was
+// last unmodified, so that the changes can be recovered with Buffer.Replay
+// if the editor dies before they're written. The journal is reset once the
+// buffer's file holds its contents, when the whole file is read into the
+// buffer or the whole buffer is written to it. The buffer doesn't report
+// errors writing to or resetting its journal, Err returns them.
+type Journal interface {
+	io.Writer
+	Reset() error
+
+	// Err returns the first error writing to or resetting the journal
+	// since Err was last called, if any
+	Err() error
+}
+
+// journalHeader returns the first line of a journal of changes to the file
+// filename as it is now. It holds the file's size and modification time so
+// that the journal is only replayed onto the file it was started against.
+func journalHeader(filename string) string {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return "ed-journal -1 0\n"
+	}
+	return fmt.Sprintf("ed-journal %d %d\n", fi.Size(), fi.ModTime().UnixNano())
+}
+
+// JournalName returns the name of the journal kept beside filename
+func JournalName(filename string) string {
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, "."+base+".ed-journal")
+}
+
+// OpenJournal opens the journal kept beside filename and locks it, returning
+// ErrJournalLocked if it belongs to an editor that's still running. The lock
+// is released when the file is closed.
+func OpenJournal(filename string) (*os.File, error) {
+	return openJournal(JournalName(filename), os.O_RDWR|os.O_APPEND)
+}
+
+// RecoverJournal replays the journal f opened with OpenJournal into buf and
+// makes it the buffer's journal, so that later changes are added to it and
+// it's removed once they're all written. f is left open only if it succeeds.
+func RecoverJournal(buf Buffer, f *os.File) error {
+	if err := buf.Replay(f); err != nil {
+		return err
+	}
+	buf.SetJournal(&fileJournal{buf: buf, f: f})
+	return nil
+}
+
+// openJournal opens the journal name with flag and locks it, the journal of
+// an editor that's running is locked until it exits or the journal is reset
+func openJournal(name string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(name, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// fileJournal is a Journal kept beside the file being edited which is only
+// created once there is a change to record. It's locked while it's open so
+// that no other editor appends to it or removes it, and a journal it didn't
+// create or recover, left by another editor, is never written to or removed.
+type fileJournal struct {
+	buf  Buffer
+	f    *os.File
+	lost bool
+	err  error
+
+	// header is the journal's header for the buffer's file as it was when
+	// the buffer last held its contents, see journalHeader
+	header string
+}
+
+// NewJournal returns a Journal for buf kept beside the buffer's file, see
+// JournalName, which must hold the contents of its file if it has one.
+// Changes made while the buffer has no filename can't be recovered and the
+// journal is abandoned until it's next reset.
+func NewJournal(buf Buffer) Journal {
+	j := &fileJournal{buf: buf}
+	if buf.Filename() != "" {
+		j.header = journalHeader(buf.Filename())
+	}
+	return j
+}
+
+func (j *fileJournal) Write(p []byte) (int, error) {
+	if j.lost {
+		return len(p), nil
+	}
+
+	if j.f == nil {
+		if j.buf.Filename() == "" {
+			j.lost = true
+			return len(p), nil
+		}
+
+		f, err := openJournal(JournalName(j.buf.Filename()), os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY)
+		if os.IsExist(err) {
+			err = ErrJournalExists
+		}
+		if err != nil {
+			j.lost = true
+			return 0, j.fail(err)
+		}
+		j.f = f
+
+		if j.header == "" {
+			j.header = journalHeader(j.buf.Filename())
+		}
+		if _, err := io.WriteString(f, j.header); err != nil {
+			return 0, j.fail(err)
+		}
+	}
+
+	n, err := j.f.Write(p)
+	return n, j.fail(err)
+}
+
+// fail records err, if not nil, to be returned by Err
+func (j *fileJournal) fail(err error) error {
+	if err != nil && j.err == nil {
+		j.err = err
+	}
+	return err
+}
+
+func (j *fileJournal) Err() error {
+	err := j.err
+	j.err = nil
+	return err
+}
+
+// Reset removes the journal if it was created or recovered by this journal.
+// A journal created afterwards is for the buffer's file as it is now.
+func (j *fileJournal) Reset() error {
+	j.lost = false
+	j.header = ""
+	if j.buf.Filename() != "" {
+		j.header = journalHeader(j.buf.Filename())
+	}
+
+	if j.f == nil {
+		return nil
+	}
+
+	// The journal is removed before it's closed and unlocked
+	err := os.Remove(j.f.Name())
+	j.f.Close()
+	j.f = nil
+	if err != nil && !os.IsNotExist(err) {
+		return j.fail(err)
+	}
+	return nil
+}
+
+// resetJournal resets the journal of buf, if it has one, once the buffer's
+// file holds its contents
+func resetJournal(buf Buffer) {
+	if j := buf.Journal(); j != nil {
+		j.Reset()
+	}
+}
+
+func (b *buffer) Journal() Journal {
+	return b.journal
+}
+
+// SetJournal makes the buffer record its changes in j, or stop recording
+// them if j is nil
+func (b *buffer) SetJournal(j Journal) {
+	b.journal = j
+}
+
+// journalChange records a change that has been made to the buffer in its
+// journal, a replaced line is recorded with its new text
+func (b *buffer) journalChange(c change) {
+	if b.journal == nil {
+		return
+	}
+
+	var rec bytes.Buffer
+	switch c.op {
+	case opInsert:
+		fmt.Fprintf(&rec, "i %d %d\n", c.at, len(c.lines))
+		for _, l := range c.lines {
+			rec.WriteString(l.text)
+			rec.WriteByte('\n')
+		}
+	case opRemove:
+		fmt.Fprintf(&rec, "d %d %d\n", c.at, len(c.lines))
+	case opReplace:
+		fmt.Fprintf(&rec, "r %d\n%s\n", c.at, c.text)
+	case opMove:
+		fmt.Fprintf(&rec, "m %d %d %d\n", c.at, c.to, len(c.lines))
+	}
+
+	// The journal keeps any error for the editor to report, see Journal.Err
+	b.journal.Write(rec.Bytes())
+}
+
+// Replay applies the changes recorded in a journal to the buffer which must
+// hold the file as it was when the journal was started, ErrJournalMismatch is
+// returned if the file has changed since. A change that was only partly
+// recorded, as the editor died, is ignored. The changes aren't recorded in
+// the buffer's own journal and the last line becomes the current line.
+func (b *buffer) Replay(r io.Reader) error {
+	// The changes are already in the journal
+	j := b.journal
+	b.journal = nil
+	defer func() {
+		b.journal = j
+	}()
+
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if !strings.HasPrefix(header, "ed-journal ") {
+		return ErrInvalidJournal
+	}
+	if header != journalHeader(b.filename) {
+		return ErrJournalMismatch
+	}
+
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		var (
+			op        byte
+			at, to, n int
+		)
+
+		if _, err := fmt.Sscanf(header, "%c %d", &op, &at); err != nil {
+			return ErrInvalidJournal
+		}
+
+		switch op {
+		case 'i':
+			if _, err := fmt.Sscanf(header, "i %d %d\n", &at, &n); err != nil {
+				return ErrInvalidJournal
+			}
+			lines := make([]*line, n)
+			for i := range lines {
+				text, err := br.ReadString('\n')
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				lines[i] = &line{text: text[:(len(text) - 1)]}
+			}
+			if at < 0 || at > b.lines.Len() {
+				return ErrInvalidJournal
+			}
+			b.insert(at, lines...)
+		case 'd':
+			if _, err := fmt.Sscanf(header, "d %d %d\n", &at, &n); err != nil {
+				return ErrInvalidJournal
+			}
+			if at < 0 || n < 0 || at+n > b.lines.Len() {
+				return ErrInvalidJournal
+			}
+			b.remove(at, (at + n))
+		case 'r':
+			text, err := br.ReadString('\n')
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if at < 0 || at >= b.lines.Len() {
+				return ErrInvalidJournal
+			}
+			b.replace(at, text[:(len(text)-1)])
+		case 'm':
+			if _, err := fmt.Sscanf(header, "m %d %d %d\n", &at, &to, &n); err != nil {
+				return ErrInvalidJournal
+			}
+			if at < 0 || n < 0 || at+n > b.lines.Len() || to < 0 || to > b.lines.Len()-n {
+				return ErrInvalidJournal
+			}
+			c := change{op: opMove, at: at, to: to, lines: b.lines.Slice(at, (at + n))}
+			b.apply(&c, false)
+		default:
+			return ErrInvalidJournal
+		}
+
+		b.index = b.lines.Len()
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package ed
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempDir returns a new temporary directory and a function removing it
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "ed-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeFiles writes each of files, a map of file names to their contents, in
+// dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEditLeavesOtherJournalsAlone(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+
+	stale := "d 0 1\n"
+	writeFiles(t, dir, map[string]string{
+		"b.txt":             "foo\nbar\n",
+		"c.txt":             "baz\n",
+		".b.txt.ed-journal": stale,
+		".c.txt.ed-journal": stale,
+	})
+
+	e, _ := newTestEditor(t, "")
+	e.Buffer().SetJournal(NewJournal(e.Buffer()))
+	for _, line := range []string{"e " + filepath.Join(dir, "b.txt"), "o " + filepath.Join(dir, "c.txt")} {
+		if _, err := e.Execute(line); err != nil {
+			t.Fatalf("Execute(%q) failed: %s", line, err)
+		}
+	}
+
+	// Changes to the files can't be journaled without the journals left
+	// beside them
+	if _, err := e.Execute("1d"); !errors.Is(err, ErrJournalExists) {
+		t.Errorf("Execute(1d) gave %v, want %v", err, ErrJournalExists)
+	}
+
+	for _, name := range []string{".b.txt.ed-journal", ".c.txt.ed-journal"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("journal %s was removed: %s", name, err)
+		} else if string(data) != stale {
+			t.Errorf("journal %s holds %q, want %q", name, data, stale)
+		}
+	}
+}
+
+func TestReplayRefusesChangedFile(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	filename := filepath.Join(dir, "a.txt")
+	writeFiles(t, dir, map[string]string{"a.txt": "foo\nbar\n"})
+
+	e, _ := newTestEditor(t, "")
+	if _, err := e.Execute("e " + filename); err != nil {
+		t.Fatal(err)
+	}
+	e.Buffer().SetJournal(NewJournal(e.Buffer()))
+	if _, err := e.Execute("1d"); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := ioutil.ReadFile(JournalName(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is changed, as by checking out another version, after the
+	// editor has died
+	writeFiles(t, dir, map[string]string{"a.txt": "baz\nfoo\nbar\n"})
+
+	buf := NewBuffer()
+	buf.SetFilename(filename)
+	if _, err := buf.ReadFrom(strings.NewReader("baz\nfoo\nbar\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.Replay(bytes.NewReader(journal)); !errors.Is(err, ErrJournalMismatch) {
+		t.Errorf("Replay gave %v, want %v", err, ErrJournalMismatch)
+	}
+	if got := buf.Select(NewAddress(1, buf.Size())); len(got) != 3 {
+		t.Errorf("Replay changed the buffer to %q", got)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []string
+	}{
+		{"insert", "", []string{"1a", "new", "lines", ".", "0i", "first", "."}},
+		{"delete", "", []string{"2d", "$d"}},
+		{"replace", "", []string{"1s/o/0/g", ",s/a/A/"}},
+		{"change", "", []string{"2,3c", "changed", "."}},
+		{"join", "", []string{"1,3j"}},
+		{"move", "", []string{"1m$", "3,4m0", "2m3"}},
+		{"transfer", "", []string{"1,2t$", "4t0"}},
+		{"global", "", []string{"g/o/m0", "v/o/s/$/!/"}},
+		{"global command list", "s/b/B/\n", []string{`g/o/s/o/0/\`}},
+		{"undo and redo", "", []string{"1d", "2m0", "1s/b/B/", "u", "u", "U", "$d"}},
+		{"empty lines", "", []string{"1a", "", "", ".", "2s/^$/x/"}},
+		{"delete all", "", []string{",d", "a", "new", "."}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, remove := tempDir(t)
+			defer remove()
+			filename := filepath.Join(dir, "a.txt")
+			writeFiles(t, dir, map[string]string{"a.txt": "foo\nboo\nbar\nbaz\n"})
+
+			e, _ := newTestEditor(t, test.input)
+			if _, err := e.Execute("e " + filename); err != nil {
+				t.Fatal(err)
+			}
+			e.Buffer().SetJournal(NewJournal(e.Buffer()))
+			for _, line := range test.lines {
+				if _, err := e.Execute(line); err != nil {
+					t.Fatalf("Execute(%q) failed: %s", line, err)
+				}
+			}
+
+			// The editor dies leaving the journal, which is replayed onto
+			// the file as it was read
+			journal, err := ioutil.ReadFile(JournalName(filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := NewBuffer()
+			buf.SetFilename(filename)
+			f, err := os.Open(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := buf.ReadFrom(f); err != nil {
+				t.Fatal(err)
+			}
+			if err := buf.Replay(bytes.NewReader(journal)); err != nil {
+				t.Fatalf("Replay failed: %s\n%s", err, journal)
+			}
+
+			want := contents(e)
+			var got []string
+			if buf.Size() > 0 {
+				got = buf.Select(NewAddress(1, buf.Size()))
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("Replay gave %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRecoverJournal(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	filename := filepath.Join(dir, "a.txt")
+	writeFiles(t, dir, map[string]string{"a.txt": "foo\nbar\n"})
+
+	e, _ := newTestEditor(t, "")
+	if _, err := e.Execute("e " + filename); err != nil {
+		t.Fatal(err)
+	}
+	e.Buffer().SetJournal(NewJournal(e.Buffer()))
+	if _, err := e.Execute("1d"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The editor dies, releasing the journal's lock
+	e.Buffer().Journal().(*fileJournal).f.Close()
+
+	r, _ := newTestEditor(t, "")
+	if _, err := r.Execute("e " + filename); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenJournal(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RecoverJournal(r.Buffer(), f); err != nil {
+		t.Fatalf("RecoverJournal failed: %s", err)
+	}
+
+	// Later changes are added to the recovered journal, which is removed
+	// once the buffer is written
+	if _, err := r.Execute("$a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"baz", "."} {
+		r.Execute(line)
+	}
+	journal, err := ioutil.ReadFile(JournalName(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(journal), "d 0 1\ni 1 1\nbaz\n") {
+		t.Errorf("recovered journal holds %q", journal)
+	}
+
+	if _, err := r.Execute("w"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(JournalName(filename)); !os.IsNotExist(err) {
+		t.Errorf("journal wasn't removed once written: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != "bar\nbaz\n" {
+		t.Errorf("file holds %q, want %q", data, "bar\nbaz\n")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package ed
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the contents of f, a regular file, into memory read only.
+// Though the mapping is private its pages aren't copied unless written to,
+// so they show any later changes to the file, and reading pages lost when
+// the file is truncated raises SIGBUS.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_PRIVATE)
+}
+
+// munmapFile unmaps data mapped by mmapFile
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package ed
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mmapFile reads the contents of f into memory, files aren't mapped on
+// Windows as they can't then be replaced when writing them
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return ioutil.ReadAll(f)
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
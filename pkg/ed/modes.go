@@ -0,0 +1,8 @@
+package ed
+
+// Modes the editor can be in, ModeAppend is input mode where each line read
+// is added to the buffer until a line containing a single period
+const (
+	ModeCommand = iota
+	ModeAppend
+)
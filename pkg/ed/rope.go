@@ -0,0 +1,251 @@
+package ed
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+const (
+	// ropeChunkLines is the most lines in a chunk of lines inserted at once
+	ropeChunkLines = 512
+
+	// ropeChunkBytes is the approximate size of the chunks a file is split
+	// into when it's loaded
+	ropeChunkBytes = 64 * 1024
+)
+
+// ropeNode is a node of a rope, an implicit treap (a binary search tree keyed
+// by line number and heap ordered by priority) whose nodes hold chunks of
+// consecutive lines. A chunk loaded from a file is kept as its raw data and
+// only split into lines when one of them is needed.
+type ropeNode struct {
+	left, right *ropeNode
+	priority    uint32
+
+	// size is the number of lines in the subtree rooted at the node
+	size int
+
+	// count is the number of lines in the node's chunk, either lines or
+	// if that is nil the lines in data which all end with a newline except
+	// perhaps the last
+	count int
+	lines []*line
+	data  []byte
+	crlf  bool
+}
+
+func newRopeNode(lines []*line) *ropeNode {
+	n := &ropeNode{priority: rand.Uint32(), count: len(lines), lines: lines}
+	n.update()
+	return n
+}
+
+func (n *ropeNode) update() {
+	n.size = n.count + n.left.len() + n.right.len()
+}
+
+func (n *ropeNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// materialize splits a chunk loaded from a file into lines, their text is
+// copied so the lines never refer to the file
+func (n *ropeNode) materialize() {
+	if n.lines != nil || n.count == 0 {
+		return
+	}
+
+	n.lines = make([]*line, 0, n.count)
+	for data := n.data; len(data) > 0; {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			i = len(data)
+		}
+		text := data[:i]
+		if n.crlf && i < len(data) {
+			text = bytes.TrimSuffix(text, []byte("\r"))
+		}
+		n.lines = append(n.lines, &line{text: string(text)})
+		if i < len(data) {
+			i++
+		}
+		data = data[i:]
+	}
+	n.data = nil
+}
+
+// merge joins the ropes a and b with a's lines before b's
+func merge(a, b *ropeNode) *ropeNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+// split splits the rope n into ropes of its first k lines and the rest
+func split(n *ropeNode, k int) (*ropeNode, *ropeNode) {
+	if n == nil {
+		return nil, nil
+	}
+
+	left := n.left.len()
+	switch {
+	case k <= left:
+		a, b := split(n.left, k)
+		n.left = b
+		n.update()
+		return a, n
+	case k >= left+n.count:
+		a, b := split(n.right, (k - left - n.count))
+		n.right = a
+		n.update()
+		return n, b
+	}
+
+	// The split is within the node's chunk, the lines after it go into a
+	// new node at the start of the right hand rope
+	n.materialize()
+	i := k - left
+	tail := newRopeNode(append([]*line(nil), n.lines[i:]...))
+	n.lines, n.count = n.lines[:i:i], i
+	right := n.right
+	n.right = nil
+	n.update()
+	return n, merge(tail, right)
+}
+
+// ropeStore is a line store for large files. Lines are held in a rope so
+// inserting, removing and finding lines takes time logarithmic in the number
+// of lines, and a file can be loaded without splitting it into lines first.
+type ropeStore struct {
+	root *ropeNode
+}
+
+func (r *ropeStore) Len() int {
+	return r.root.len()
+}
+
+func (r *ropeStore) At(i int) *line {
+	n := r.root
+	for {
+		left := n.left.len()
+		if i < left {
+			n = n.left
+		} else if i < left+n.count {
+			n.materialize()
+			return n.lines[(i - left)]
+		} else {
+			i -= left + n.count
+			n = n.right
+		}
+	}
+}
+
+func (r *ropeStore) Slice(start, end int) []*line {
+	lines := make([]*line, 0, (end - start))
+	collect(r.root, start, end, &lines)
+	return lines
+}
+
+// collect appends the lines of n with indexes start up to end to lines
+func collect(n *ropeNode, start, end int, lines *[]*line) {
+	if n == nil || start >= end {
+		return
+	}
+
+	left := n.left.len()
+	if start < left {
+		collect(n.left, start, min(end, left), lines)
+	}
+	if start < left+n.count && end > left {
+		n.materialize()
+		from, to := max(start-left, 0), min(end-left, n.count)
+		*lines = append(*lines, n.lines[from:to]...)
+	}
+	if end > left+n.count {
+		collect(n.right, max(start-left-n.count, 0), (end - left - n.count), lines)
+	}
+}
+
+func (r *ropeStore) Insert(at int, lines []*line) {
+	a, b := split(r.root, at)
+	for len(lines) > 0 {
+		k := min(len(lines), ropeChunkLines)
+		a = merge(a, newRopeNode(append([]*line(nil), lines[:k]...)))
+		lines = lines[k:]
+	}
+	r.root = merge(a, b)
+}
+
+func (r *ropeStore) Remove(start, end int) {
+	a, rest := split(r.root, start)
+	_, b := split(rest, (end - start))
+	r.root = merge(a, b)
+}
+
+// materializeAll splits all the chunks loaded from a file into lines, so
+// that no lines refer to the file
+func (r *ropeStore) materializeAll() {
+	var walk func(n *ropeNode)
+	walk = func(n *ropeNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		n.materialize()
+		walk(n.right)
+	}
+	walk(r.root)
+}
+
+// Load appends the lines of data, a file's contents, to the rope without
+// splitting it into lines. The carriage returns of lines ending with \r\n
+// are removed if crlf is true.
+func (r *ropeStore) Load(data []byte, crlf bool) {
+	for len(data) > 0 {
+		size := len(data)
+		if size > ropeChunkBytes {
+			if i := bytes.IndexByte(data[ropeChunkBytes:], '\n'); i >= 0 {
+				size = ropeChunkBytes + i + 1
+			}
+		}
+
+		chunk := data[:size]
+		n := &ropeNode{priority: rand.Uint32(), data: chunk, crlf: crlf}
+		n.count = bytes.Count(chunk, []byte("\n"))
+		if chunk[(len(chunk)-1)] != '\n' {
+			n.count++
+		}
+		n.update()
+
+		r.root = merge(r.root, n)
+		data = data[size:]
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
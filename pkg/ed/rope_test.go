@@ -0,0 +1,183 @@
+package ed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// texts returns the text of lines
+func texts(lines []*line) []string {
+	s := make([]string, len(lines))
+	for i, l := range lines {
+		s[i] = l.text
+	}
+	return s
+}
+
+// newLines returns n lines of text from rng, some of which are empty
+func newLines(rng *rand.Rand, n int) []*line {
+	lines := make([]*line, n)
+	for i := range lines {
+		lines[i] = &line{text: strings.Repeat(fmt.Sprintf("%x", rng.Int63()), rng.Intn(4))}
+	}
+	return lines
+}
+
+// loadData returns the contents of a file of n lines from rng spanning
+// several rope chunks, with \r\n line endings if crlf is true and without a
+// line ending on the last line if unterminated is true, and its lines
+func loadData(rng *rand.Rand, n int, crlf, unterminated bool) ([]byte, []*line) {
+	lines := newLines(rng, n)
+
+	// An empty last line without a line ending isn't a line at all
+	if unterminated && lines[(n-1)].text == "" {
+		lines[(n - 1)].text = "last"
+	}
+
+	var sb strings.Builder
+	for i, l := range lines {
+		sb.WriteString(l.text)
+		if i == (n-1) && unterminated {
+			break
+		}
+		if crlf {
+			sb.WriteString("\r\n")
+		} else {
+			sb.WriteString("\n")
+		}
+	}
+
+	return []byte(sb.String()), lines
+}
+
+// checkStores fails the test if the stores don't hold the same lines
+func checkStores(t *testing.T, op string, rope *ropeStore, slice *sliceStore) {
+	t.Helper()
+
+	if rope.Len() != slice.Len() {
+		t.Fatalf("after %s rope has %d lines, want %d", op, rope.Len(), slice.Len())
+	}
+	got, want := texts(rope.Slice(0, rope.Len())), texts(slice.Slice(0, slice.Len()))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("after %s rope line %d is %q, want %q", op, i, got[i], want[i])
+		}
+	}
+}
+
+func TestRopeStoreMatchesSliceStore(t *testing.T) {
+	tests := []struct {
+		name         string
+		crlf         bool
+		unterminated bool
+	}{
+		{"lf", false, false},
+		{"crlf", true, false},
+		{"unterminated", false, true},
+		{"crlf unterminated", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+
+			// The file is loaded lazily in chunks, the operations on the
+			// rope must split and materialize them as needed
+			data, lines := loadData(rng, 8000, test.crlf, test.unterminated)
+			rope, slice := &ropeStore{}, &sliceStore{}
+			rope.Load(data, test.crlf)
+			slice.Insert(0, lines)
+			checkStores(t, "Load", rope, slice)
+
+			for i := 0; i < 2000; i++ {
+				var op string
+
+				switch n := slice.Len(); rng.Intn(4) {
+				case 0:
+					// Now and then more lines than fit in a chunk
+					k := rng.Intn(10)
+					if rng.Intn(20) == 0 {
+						k = rng.Intn(2 * ropeChunkLines)
+					}
+					at, lines := rng.Intn(n+1), newLines(rng, k)
+					op = fmt.Sprintf("Insert(%d, %d lines)", at, len(lines))
+					rope.Insert(at, lines)
+					slice.Insert(at, append([]*line(nil), lines...))
+				case 1:
+					start := rng.Intn(n + 1)
+					end := start + rng.Intn(min(n-start, 100)+1)
+					op = fmt.Sprintf("Remove(%d, %d)", start, end)
+					rope.Remove(start, end)
+					slice.Remove(start, end)
+				case 2:
+					start := rng.Intn(n + 1)
+					end := start + rng.Intn(n-start+1)
+					op = fmt.Sprintf("Slice(%d, %d)", start, end)
+					got, want := texts(rope.Slice(start, end)), texts(slice.Slice(start, end))
+					if strings.Join(got, "\n") != strings.Join(want, "\n") || len(got) != len(want) {
+						t.Fatalf("%s gave %d lines differing from the %d wanted", op, len(got), len(want))
+					}
+				case 3:
+					if n == 0 {
+						continue
+					}
+					at := rng.Intn(n)
+					op = fmt.Sprintf("At(%d)", at)
+					if got, want := rope.At(at).text, slice.At(at).text; got != want {
+						t.Fatalf("%s is %q, want %q", op, got, want)
+					}
+				}
+
+				if i%100 == 0 {
+					checkStores(t, op, rope, slice)
+				}
+			}
+			checkStores(t, "all operations", rope, slice)
+		})
+	}
+}
+
+func TestRopeBufferWritesItsFileInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ed-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The file has another link so it's written in place, truncating the
+	// file the buffer has mapped
+	filename := filepath.Join(dir, "big")
+	data, lines := loadData(rand.New(rand.NewSource(1)), 8000, false, false)
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filename, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewEditor(WithBuffers(NewRopeBuffer), WithOutput(ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := e.Buffer().ReadFrom(f); err != nil {
+		t.Fatal(err)
+	}
+	e.SetFilename(filename)
+
+	if _, err := e.Execute("1,2w"); err != nil {
+		t.Fatalf("Execute(1,2w) failed: %s", err)
+	}
+	if got, want := contents(e), texts(lines); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("buffer has %d lines after writing part of its file, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,38 @@
+package ed
+
+// lineStore holds the lines of a buffer addressed by (zero based) index. The
+// buffer makes all changes through Insert and Remove, Slice and At may be
+// used to find lines.
+type lineStore interface {
+	Len() int
+	At(i int) *line
+	Slice(start, end int) []*line
+	Insert(at int, lines []*line)
+	Remove(start, end int)
+}
+
+// sliceStore is the default line store, a slice of lines, which is fast to
+// index but every insertion or removal moves the lines after it
+type sliceStore struct {
+	lines []*line
+}
+
+func (s *sliceStore) Len() int {
+	return len(s.lines)
+}
+
+func (s *sliceStore) At(i int) *line {
+	return s.lines[i]
+}
+
+func (s *sliceStore) Slice(start, end int) []*line {
+	return append([]*line(nil), s.lines[start:end]...)
+}
+
+func (s *sliceStore) Insert(at int, lines []*line) {
+	s.lines = append(s.lines[:at], append(lines, s.lines[at:]...)...)
+}
+
+func (s *sliceStore) Remove(start, end int) {
+	s.lines = append(s.lines[:start], s.lines[end:]...)
+}
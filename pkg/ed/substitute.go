@@ -0,0 +1,167 @@
+package ed
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// substitution is a parsed s/re/replacement/flags command
+type substitution struct {
+	expr   string
+	repl   string
+	nth    int
+	global bool
+}
+
+// parseSubstitution parses the arguments of the s command, everything after
+// the s itself, into its regular expression, replacement and flags.
+func parseSubstitution(args string) (sub substitution, err error) {
+	if args == "" || args[0] == ' ' || args[0] == '\\' {
+		err = ErrInvalidDelimiter
+		return
+	}
+
+	delim := args[0]
+
+	expr, rest, ok := splitPattern(args[1:], delim)
+	if !ok {
+		err = ErrInvalidDelimiter
+		return
+	}
+
+	// The print suffixes have already been removed from the flags, see
+	// ParseCommand. An escaped & delimiter is a literal & in the replacement.
+	escaped := string(delim)
+	if delim == '&' {
+		escaped = `\&`
+	}
+	repl, flags, _ := splitField(rest, delim, escaped)
+
+	sub.expr, sub.repl, sub.nth = expr, repl, 1
+
+	for i := 0; i < len(flags); i++ {
+		switch c := flags[i]; {
+		case c == 'g':
+			sub.global = true
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(flags) && flags[j] >= '0' && flags[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(flags[i:j])
+			if n < 1 {
+				err = ErrInvalidSuffix
+				return
+			}
+			sub.nth = n
+			i = j - 1
+		default:
+			err = ErrInvalidSuffix
+			return
+		}
+	}
+
+	return
+}
+
+// splitDelim splits s at the first unescaped delim returning the field before
+// it (with escaped delimiters unescaped) and the remainder after it.
+// ok is false if no terminating delimiter was found.
+func splitDelim(s string, delim byte) (field, rest string, ok bool) {
+	return splitField(s, delim, string(delim))
+}
+
+// splitPattern splits a regular expression from s as splitDelim does, an
+// escaped delimiter matches itself even if it's a metacharacter, e.g. the |
+// of s|a\|b|c|
+func splitPattern(s string, delim byte) (expr, rest string, ok bool) {
+	return splitField(s, delim, regexp.QuoteMeta(string(delim)))
+}
+
+// splitField splits s at the first unescaped delim returning the field before
+// it, with escaped delimiters replaced by escaped, and the remainder after it
+func splitField(s string, delim byte, escaped string) (field, rest string, ok bool) {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == delim {
+				buf = append(buf, escaped...)
+				continue
+			}
+			buf = append(buf, c, s[i])
+		case c == delim:
+			return string(buf), s[i+1:], true
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf), "", false
+}
+
+// checkBackReferences ensures every \1..\9 in the replacement refers to a
+// parenthesized subexpression of re
+func checkBackReferences(re *regexp.Regexp, repl string) error {
+	for i := 0; i < len(repl); i++ {
+		if repl[i] != '\\' || i+1 == len(repl) {
+			continue
+		}
+		i++
+		if c := repl[i]; c >= '1' && c <= '9' && int(c-'0') > re.NumSubexp() {
+			return ErrInvalidBackReference
+		}
+	}
+	return nil
+}
+
+// substitute replaces the nth match of re in line (and every match after it
+// if global is true) with repl. ok is false if no substitution was made.
+func substitute(re *regexp.Regexp, line, repl string, nth int, global bool) (result string, ok bool) {
+	var (
+		buf  []byte
+		last int
+	)
+
+	for i, match := range re.FindAllStringSubmatchIndex(line, -1) {
+		if i+1 < nth || (i+1 > nth && !global) {
+			continue
+		}
+		buf = append(buf, line[last:match[0]]...)
+		buf = expand(buf, repl, line, match)
+		last = match[1]
+		ok = true
+	}
+
+	if !ok {
+		return line, false
+	}
+
+	buf = append(buf, line[last:]...)
+	return string(buf), true
+}
+
+// expand appends the replacement template repl to dst replacing & with the
+// whole match and \1..\9 with the corresponding subexpression of src
+func expand(dst []byte, repl, src string, match []int) []byte {
+	for i := 0; i < len(repl); i++ {
+		switch c := repl[i]; {
+		case c == '&':
+			dst = append(dst, src[match[0]:match[1]]...)
+		case c == '\\' && i+1 < len(repl):
+			i++
+			c = repl[i]
+			if c >= '1' && c <= '9' {
+				n := int(c - '0')
+				if 2*n+1 < len(match) && match[2*n] >= 0 {
+					dst = append(dst, src[match[2*n]:match[2*n+1]]...)
+				}
+			} else {
+				dst = append(dst, c)
+			}
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
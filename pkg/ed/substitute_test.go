@@ -0,0 +1,201 @@
+package ed
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEscapedDelimiters(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`1s|a\|b|X|`, "X a.b a?b a/b,axb,ab"},
+		{`1s.a\.b.X.`, "a|b X a?b a/b,axb,ab"},
+		{`1s?a\?b?X?`, "a|b a.b X a/b,axb,ab"},
+		{`1s/a\/b/X/`, "a|b a.b a?b X,axb,ab"},
+		{`1s&a/b&\&&`, "a|b a.b a?b &,axb,ab"},
+		{`g|a\|b|s/^a/Z/`, "Z|b a.b a?b a/b,axb,ab"},
+		{`?a\?b?s/^a/Z/`, "Z|b a.b a?b a/b,axb,ab"},
+	}
+
+	for _, test := range tests {
+		e, _ := newTestEditor(t, "", "a|b a.b a?b a/b", "axb", "ab")
+		if _, err := e.Execute(test.line); err != nil {
+			t.Errorf("Execute(%q) failed: %s", test.line, err)
+			continue
+		}
+		if got := strings.Join(contents(e), ","); got != test.want {
+			t.Errorf("Execute(%q) gave %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseSubstitution(t *testing.T) {
+	tests := []struct {
+		args string
+		want substitution
+		err  error
+	}{
+		{"/a/b/", substitution{"a", "b", 1, false}, nil},
+		{"/a/b", substitution{"a", "b", 1, false}, nil},
+		{"/a/", substitution{"a", "", 1, false}, nil},
+		{"//b/", substitution{"", "b", 1, false}, nil},
+		{"/a/b/g", substitution{"a", "b", 1, true}, nil},
+		{"/a/b/3", substitution{"a", "b", 3, false}, nil},
+		{"/a/b/12g", substitution{"a", "b", 12, true}, nil},
+		{"/a/b/g2", substitution{"a", "b", 2, true}, nil},
+		{"/a/%/", substitution{"a", "%", 1, false}, nil},
+		{`/a\/b/c\/d/`, substitution{"a/b", "c/d", 1, false}, nil},
+		{`/a\.b/\1/`, substitution{`a\.b`, `\1`, 1, false}, nil},
+		{"/a/b/0", substitution{}, ErrInvalidSuffix},
+		{"/a/b/x", substitution{}, ErrInvalidSuffix},
+		{"", substitution{}, ErrInvalidDelimiter},
+		{" a b ", substitution{}, ErrInvalidDelimiter},
+		{`\a\b\`, substitution{}, ErrInvalidDelimiter},
+		{"/a", substitution{}, ErrInvalidDelimiter},
+	}
+
+	for _, test := range tests {
+		sub, err := parseSubstitution(test.args)
+		if err != test.err {
+			t.Errorf("parseSubstitution(%q) gave error %v, want %v", test.args, err, test.err)
+			continue
+		}
+		if err == nil && sub != test.want {
+			t.Errorf("parseSubstitution(%q) = %+v, want %+v", test.args, sub, test.want)
+		}
+	}
+}
+
+func TestCheckBackReferences(t *testing.T) {
+	tests := []struct {
+		expr string
+		repl string
+		err  error
+	}{
+		{"a", "b", nil},
+		{"(a)", `\1`, nil},
+		{"(a)(b)", `\2\1`, nil},
+		{"(a)", `\2`, ErrInvalidBackReference},
+		{"a", `\1`, ErrInvalidBackReference},
+		{"a", `\0`, nil},
+		{"a", `\\1`, nil},
+		{"a", `x\`, nil},
+	}
+
+	for _, test := range tests {
+		re := regexp.MustCompile(test.expr)
+		if err := checkBackReferences(re, test.repl); err != test.err {
+			t.Errorf("checkBackReferences(%q, %q) gave %v, want %v", test.expr, test.repl, err, test.err)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		expr   string
+		line   string
+		repl   string
+		nth    int
+		global bool
+		want   string
+		ok     bool
+	}{
+		{"o", "foo boo", "0", 1, false, "f0o boo", true},
+		{"o", "foo boo", "0", 1, true, "f00 b00", true},
+		{"o", "foo boo", "0", 3, false, "foo b0o", true},
+		{"o", "foo boo", "0", 3, true, "foo b00", true},
+		{"o", "foo boo", "0", 5, false, "foo boo", false},
+		{"x", "foo boo", "0", 1, true, "foo boo", false},
+		{"o+", "foo boo", "[&]", 1, true, "f[oo] b[oo]", true},
+		{"o+", "foo boo", `\&`, 1, false, "f& boo", true},
+		{`(\w+) (\w+)`, "foo boo", `\2 \1`, 1, false, "boo foo", true},
+		{`(a)|(f)`, "foo", `<\1\2>`, 1, false, "<f>oo", true},
+		{"o", "foo", `\n\\`, 1, false, `fn\o`, true},
+		{"x*", "abc", "-", 1, true, "-a-b-c-", true},
+		{"x*", "abc", "-", 2, false, "a-bc", true},
+		{"^", "abc", "> ", 1, false, "> abc", true},
+		{"$", "abc", ";", 1, false, "abc;", true},
+		{"", "", "x", 1, false, "x", true},
+	}
+
+	for _, test := range tests {
+		re := regexp.MustCompile(test.expr)
+		got, ok := substitute(re, test.line, test.repl, test.nth, test.global)
+		if got != test.want || ok != test.ok {
+			t.Errorf("substitute(%q, %q, %q, %d, %t) = %q, %t, want %q, %t",
+				test.expr, test.line, test.repl, test.nth, test.global, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		repl string
+		want string
+	}{
+		{"plain", "plain"},
+		{"&", "foo-bar"},
+		{"[&&]", "[foo-barfoo-bar]"},
+		{`\1`, "foo"},
+		{`\2.\1`, "bar.foo"},
+		{`\3`, ""},
+		{`\9`, ""},
+		{`\&`, "&"},
+		{`\\`, `\`},
+		{`x\`, `x\`},
+	}
+
+	src := "<foo-bar>"
+	match := regexp.MustCompile(`(\w+)-(\w+)(x)?`).FindStringSubmatchIndex(src)
+	for _, test := range tests {
+		if got := string(expand(nil, test.repl, src, match)); got != test.want {
+			t.Errorf("expand(%q) = %q, want %q", test.repl, got, test.want)
+		}
+	}
+}
+
+func TestExecuteSubstitute(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+		err   error
+	}{
+		{"first match", []string{",s/o/0/"}, "f0o,b0o,bar", nil},
+		{"global", []string{",s/o/0/g"}, "f00,b00,bar", nil},
+		{"nth match", []string{",s/o/0/2"}, "fo0,bo0,bar", nil},
+		{"no match in some lines", []string{",s/a/A/"}, "foo,boo,bAr", nil},
+		{"no match", []string{",s/x/y/"}, "foo,boo,bar", ErrNoMatch},
+		{"previous replacement", []string{"1s/o/0/", "2s/b/%/"}, "f0o,0oo,bar", nil},
+		{"no previous replacement", []string{"s/a/%/"}, "foo,boo,bar", ErrNoPreviousReplacement},
+		{"previous expression", []string{"/oo/", "s//00/"}, "foo,b00,bar", nil},
+		{"repeated previous", []string{"1s/o/&&/g", "2s//%/g"}, "foooo,boooo,bar", nil},
+		{"back reference", []string{`3s/(b)(a)/\2\1/`}, "foo,boo,abr", nil},
+		{"invalid back reference", []string{`3s/b/\1/`}, "foo,boo,bar", ErrInvalidBackReference},
+		{"empty matches", []string{`1s/x*/-/g`}, "-f-o-o-,boo,bar", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, "", "foo", "boo", "bar")
+			e.Execute("1")
+
+			var err error
+			for _, line := range test.lines {
+				if _, err = e.Execute(line); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("%q gave %v, want %v", test.lines, err, test.err)
+			}
+			if got := strings.Join(contents(e), ","); got != test.want {
+				t.Errorf("%q gave %q, want %q", test.lines, got, test.want)
+			}
+		})
+	}
+}
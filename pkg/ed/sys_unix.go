@@ -0,0 +1,117 @@
+//go:build !windows
+// +build !windows
+
+package ed
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// setProcessGroup makes cmd start in its own process group so that it can be
+// killed along with any processes it starts. If the editor is in the
+// foreground of its terminal the command's group is put in the foreground
+// instead so that it can read from the terminal, e.g. a password prompt, and
+// restore must be called once the command has exited to take it back.
+func setProcessGroup(cmd *exec.Cmd) (restore func()) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return func() {}
+	}
+	pgrp, err := tcgetpgrp(tty)
+	if err != nil || pgrp != syscall.Getpgrp() {
+		tty.Close()
+		return func() {}
+	}
+
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = int(tty.Fd())
+
+	return func() {
+		// The editor is in the background until it has the terminal back
+		// and would be stopped by SIGTTOU for taking it
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+
+		tcsetpgrp(tty, pgrp)
+		tty.Close()
+	}
+}
+
+// tcgetpgrp returns the foreground process group of the terminal tty
+func tcgetpgrp(tty *os.File) (int, error) {
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// tcsetpgrp makes pgrp the foreground process group of the terminal tty
+func tcsetpgrp(tty *os.File, pgrp int) error {
+	id := int32(pgrp)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// killProcessGroup kills the started command and any processes it started
+// which are in its process group
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// chown gives f the same owner and group as fi, only the superuser can give a
+// file away so this fails if fi belongs to someone else
+func chown(f *os.File, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
+
+// links returns the number of hard links to the file fi
+func links(fi os.FileInfo) int {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int(st.Nlink)
+	}
+	return 1
+}
+
+// syncDir syncs the directory dir so that changes to its entries are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// lockFile takes an exclusive lock on f which is held until it's closed, even
+// if the process dies, returning ErrJournalLocked if another process has it
+func lockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), (syscall.LOCK_EX | syscall.LOCK_NB)); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return ErrJournalLocked
+		}
+		return err
+	}
+	return nil
+}
+
+// umask returns the process's file mode creation mask
+func umask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
@@ -0,0 +1,40 @@
+//go:build windows
+// +build windows
+
+package ed
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) (restore func()) {
+	return func() {}
+}
+
+// killProcessGroup kills the started command, Windows has no process groups
+// so any processes it started are left running
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func chown(f *os.File, fi os.FileInfo) error {
+	return nil
+}
+
+func links(fi os.FileInfo) int {
+	return 1
+}
+
+// syncDir does nothing, directories can't be synced on Windows
+func syncDir(dir string) error {
+	return nil
+}
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func umask() os.FileMode {
+	return 0
+}
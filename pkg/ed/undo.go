@@ -0,0 +1,159 @@
+package ed
+
+const (
+	opInsert = iota
+	opRemove
+	opReplace
+	opMove
+)
+
+// change is a single primitive change to the lines of a buffer
+type change struct {
+	op    int
+	at    int
+	to    int
+	lines []*line
+	text  string
+}
+
+// transaction is the group of changes made by a single top-level command
+// and the current line before and after them, it is the unit of undo and redo
+type transaction struct {
+	before  int
+	after   int
+	changes []change
+}
+
+// Checkpoint ends the open transaction and starts a new one. The editor calls
+// it before each top-level command so that all of the changes made by the
+// command, including a whole global command, are undone together.
+func (b *buffer) Checkpoint() {
+	if b.txn != nil && len(b.txn.changes) > 0 {
+		b.txn.after = b.index
+		b.undo = append(b.undo, b.txn)
+		if b.history > 0 && len(b.undo) > b.history {
+			b.undo = b.undo[(len(b.undo) - b.history):]
+		}
+	}
+	b.txn = &transaction{before: b.index}
+}
+
+// Undo reverts the last transaction and restores the current line
+func (b *buffer) Undo() error {
+	if b.global != nil {
+		return ErrUndoInGlobal
+	}
+
+	b.Checkpoint()
+	if len(b.undo) == 0 {
+		return ErrNothingToUndo
+	}
+
+	t := b.undo[(len(b.undo) - 1)]
+	b.undo = b.undo[:(len(b.undo) - 1)]
+
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		b.apply(&t.changes[i], true)
+		b.journalChange(applied(&t.changes[i], true))
+	}
+
+	b.index = t.before
+	b.redo = append(b.redo, t)
+	b.txn = &transaction{before: b.index}
+
+	return nil
+}
+
+// Redo reapplies the last transaction reverted by Undo
+func (b *buffer) Redo() error {
+	if b.global != nil {
+		return ErrUndoInGlobal
+	}
+
+	b.Checkpoint()
+	if len(b.redo) == 0 {
+		return ErrNothingToRedo
+	}
+
+	t := b.redo[(len(b.redo) - 1)]
+	b.redo = b.redo[:(len(b.redo) - 1)]
+
+	for i := range t.changes {
+		b.apply(&t.changes[i], false)
+		b.journalChange(applied(&t.changes[i], false))
+	}
+
+	b.index = t.after
+	b.undo = append(b.undo, t)
+	b.txn = &transaction{before: b.index}
+
+	return nil
+}
+
+// SetHistory limits the number of transactions that can be undone, zero
+// (the default) means there is no limit
+func (b *buffer) SetHistory(n int) {
+	b.history = n
+}
+
+// record journals a change in the open transaction and marks the buffer
+// modified. Changes made outside of a transaction, such as reading the file
+// given on the command line, cannot be undone.
+func (b *buffer) record(c change) {
+	b.modified = true
+	if b.txn == nil {
+		return
+	}
+	b.txn.changes = append(b.txn.changes, c)
+	b.redo = nil
+}
+
+// apply replays a change, or reverts it if undo is true, without recording it
+func (b *buffer) apply(c *change, undo bool) {
+	b.modified = true
+
+	op := c.op
+	if undo && op == opInsert {
+		op = opRemove
+	} else if undo && op == opRemove {
+		op = opInsert
+	}
+
+	switch op {
+	case opInsert:
+		b.lines.Insert(c.at, append([]*line(nil), c.lines...))
+	case opRemove:
+		b.unmark(c.lines)
+		b.dropMarks(c.lines)
+		b.lines.Remove(c.at, (c.at + len(c.lines)))
+	case opReplace:
+		// The old and new text are swapped so the change can be applied
+		// again in the other direction
+		line := c.lines[0]
+		line.text, c.text = c.text, line.text
+	case opMove:
+		from, to := c.at, c.to
+		if undo {
+			from, to = to, from
+		}
+		b.lines.Remove(from, (from + len(c.lines)))
+		b.lines.Insert(to, append([]*line(nil), c.lines...))
+	}
+}
+
+// applied returns the change made to the buffer by applying c, or reverting
+// it if undo is true, with a replaced line's new text
+func applied(c *change, undo bool) change {
+	a := change{op: c.op, at: c.at, to: c.to, lines: c.lines}
+	switch {
+	case c.op == opReplace:
+		a.text = c.lines[0].text
+	case undo && c.op == opInsert:
+		a.op = opRemove
+	case undo && c.op == opRemove:
+		a.op = opInsert
+	case undo && c.op == opMove:
+		a.at, a.to = c.to, c.at
+	}
+	return a
+}
@@ -0,0 +1,156 @@
+package ed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// step is a command executed in a test and the buffer's lines, joined by
+// commas, and current line after it
+type step struct {
+	line  string
+	want  string
+	index int
+	err   error
+}
+
+// runSteps executes the steps in e failing the test if any differ
+func runSteps(t *testing.T, e Editor, steps []step) {
+	t.Helper()
+
+	for _, s := range steps {
+		res, err := e.Execute(s.line)
+		if !errors.Is(err, s.err) {
+			t.Fatalf("Execute(%q) gave %v, want %v", s.line, err, s.err)
+		}
+		if got := strings.Join(contents(e), ","); got != s.want {
+			t.Fatalf("Execute(%q) gave %q, want %q", s.line, got, s.want)
+		}
+		if s.err == nil && res.Index != s.index {
+			t.Fatalf("Execute(%q) left line %d current, want %d", s.line, res.Index, s.index)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		steps []step
+	}{
+		{"nothing to undo", "", []step{
+			{"u", "foo,boo,bar", 0, ErrNothingToUndo},
+			{"U", "foo,boo,bar", 0, ErrNothingToRedo},
+		}},
+		{"delete", "", []step{
+			{"1", "foo,boo,bar", 1, nil},
+			{"2d", "foo,bar", 2, nil},
+			{"u", "foo,boo,bar", 1, nil},
+			{"U", "foo,bar", 2, nil},
+			{"u", "foo,boo,bar", 1, nil},
+			{"u", "foo,boo,bar", 0, ErrNothingToUndo},
+		}},
+		{"several commands", "", []step{
+			{"1d", "boo,bar", 1, nil},
+			{"$a", "boo,bar", 2, nil},
+			{"baz", "boo,bar,baz", 3, nil},
+			{".", "boo,bar,baz", 3, nil},
+			{"1s/b/B/", "Boo,bar,baz", 1, nil},
+			{"u", "boo,bar,baz", 3, nil},
+			{"u", "boo,bar", 1, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"U", "boo,bar", 1, nil},
+			{"U", "boo,bar,baz", 3, nil},
+			{"U", "Boo,bar,baz", 1, nil},
+		}},
+		{"a new change drops redo", "", []step{
+			{"1d", "boo,bar", 1, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"2d", "foo,bar", 2, nil},
+			{"U", "foo,bar", 0, ErrNothingToRedo},
+		}},
+		{"global as one transaction", "", []step{
+			{"g/o/s/o/0/g", "f00,b00,bar", 2, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"U", "f00,b00,bar", 2, nil},
+		}},
+		{"global with a command list", "s/b/B/\n", []step{
+			{`g/o/s/o/0/\`, "f0o,B0o,bar", 2, nil},
+			{"u", "foo,boo,bar", 3, nil},
+		}},
+		{"global moving lines", "", []step{
+			{"g/o/m0", "boo,foo,bar", 1, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"U", "boo,foo,bar", 1, nil},
+		}},
+		{"move down", "", []step{
+			{"1m$", "boo,bar,foo", 3, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"U", "boo,bar,foo", 3, nil},
+		}},
+		{"move up", "", []step{
+			{"2,3m0", "boo,bar,foo", 2, nil},
+			{"u", "foo,boo,bar", 3, nil},
+			{"U", "boo,bar,foo", 2, nil},
+		}},
+		{"move within", "", []step{
+			{"1m2", "boo,foo,bar", 2, nil},
+			{"u", "foo,boo,bar", 3, nil},
+		}},
+		{"transfer", "", []step{
+			{"1,2t$", "foo,boo,bar,foo,boo", 5, nil},
+			{"u", "foo,boo,bar", 3, nil},
+		}},
+		{"undo in global", "", []step{
+			{"g/o/u", "foo,boo,bar", 0, ErrUndoInGlobal},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, test.input, "foo", "boo", "bar")
+			runSteps(t, e, test.steps)
+		})
+	}
+}
+
+func TestSetHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		history int
+		steps   []step
+	}{
+		{"unlimited", 0, []step{
+			{"1d", "boo,bar", 1, nil},
+			{"1d", "bar", 1, nil},
+			{"1d", "", 0, nil},
+			{"u", "bar", 1, nil},
+			{"u", "boo,bar", 1, nil},
+			{"u", "foo,boo,bar", 3, nil},
+		}},
+		{"trimmed", 2, []step{
+			{"1d", "boo,bar", 1, nil},
+			{"1d", "bar", 1, nil},
+			{"1d", "", 0, nil},
+			{"u", "bar", 1, nil},
+			{"u", "boo,bar", 1, nil},
+			{"u", "boo,bar", 0, ErrNothingToUndo},
+		}},
+		{"redo isn't trimmed", 1, []step{
+			{"1d", "boo,bar", 1, nil},
+			{"1d", "bar", 1, nil},
+			{"u", "boo,bar", 1, nil},
+			{"u", "boo,bar", 0, ErrNothingToUndo},
+			{"U", "bar", 1, nil},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := newTestEditor(t, "", "foo", "boo", "bar")
+			e.Buffer().SetHistory(test.history)
+			runSteps(t, e, test.steps)
+		})
+	}
+}
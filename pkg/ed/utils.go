@@ -0,0 +1,229 @@
+package ed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	log "github.com/sirupsen/logrus"
+)
+
+// execResult is the result of a shell command, reading from it reads the
+// command's standard output
+type execResult struct {
+	io.ReadCloser
+	Status    int
+	Output    []byte
+	Errors    []byte
+	readIndex int64
+}
+
+func (res *execResult) Close() error {
+	return nil
+}
+
+func (res *execResult) Read(p []byte) (n int, err error) {
+	if res.readIndex >= int64(len(res.Output)) {
+		err = io.EOF
+		return
+	}
+
+	n = copy(p, res.Output[res.readIndex:])
+	res.readIndex += int64(n)
+	return
+}
+
+// execShell runs cmd with the shell in dir, the current directory if empty,
+// with input as its standard input if not nil. The command's standard output
+// and error are written to stdout and stderr as it runs, or collected in the
+// result if nil. The command and any processes it started are killed if ctx
+// is cancelled, in which case ErrInterrupt is returned.
+func execShell(ctx context.Context, dir, cmd string, input io.Reader, stdout, stderr io.Writer) (res *execResult, err error) {
+	res = &execResult{}
+
+	sh := exec.Command("/bin/sh", "-c", cmd)
+	if dir != "" {
+		sh.Dir = dir
+	}
+	if input != nil {
+		sh.Stdin = input
+	}
+
+	var outbuf, errbuf bytes.Buffer
+	if stdout == nil {
+		stdout = &outbuf
+	}
+	if stderr == nil {
+		stderr = &errbuf
+	}
+	// The writers are wrapped so that copying the output doesn't use any
+	// ReadFrom method, the editor's would read it into the buffer
+	sh.Stdout, sh.Stderr = struct{ io.Writer }{stdout}, struct{ io.Writer }{stderr}
+
+	// The command runs in its own process group so that it can be killed
+	// along with its children, in the foreground if the editor is
+	restore := setProcessGroup(sh)
+	defer restore()
+
+	if err = sh.Start(); err != nil {
+		log.WithError(err).
+			WithField("cmd", cmd).
+			Debug("error starting command")
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(sh)
+		case <-done:
+		}
+	}()
+
+	err = sh.Wait()
+	close(done)
+
+	res.Output, res.Errors = outbuf.Bytes(), errbuf.Bytes()
+	if ctx.Err() != nil {
+		return res, ErrInterrupt
+	}
+	if err != nil {
+		log.WithError(err).
+			WithField("cmd", cmd).
+			Debug("error executing command")
+
+		// Shamelessly borrowed from https://github.com/prologic/je/blob/master/job.go#L247
+		if exiterr, ok := err.(*exec.ExitError); ok {
+			// The program has exited with an exit code != 0
+
+			// This works on both Unix and Windows. Although package
+			// syscall is generally platform dependent, WaitStatus is
+			// defined for both Unix and Windows and in both cases has
+			// an ExitStatus() method with the same signature.
+			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+				res.Status = status.ExitStatus()
+
+				// A command in the foreground is interrupted by the
+				// terminal rather than the editor
+				if status.Signaled() && status.Signal() == syscall.SIGINT {
+					return res, ErrInterrupt
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func fileExists(name string) bool {
+	if _, err := os.Stat(name); err != nil {
+		if os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// listLine returns line in the unambiguous form of the l command with
+// non-printable characters, \ and $ escaped and the end of the line marked
+// with $. If width isn't 0 the line is folded so that no part of it,
+// including the \ marking where it's folded, is longer than width characters.
+func listLine(line string, width int) string {
+	var sb strings.Builder
+	col := 0
+
+	// put writes the escaped form of a character, folding the line first
+	// if it doesn't fit as escapes aren't split
+	put := func(s string) {
+		n := utf8.RuneCountInString(s)
+		if width > 1 && col > 0 && (col+n) > (width-1) {
+			sb.WriteString("\\\n")
+			col = 0
+		}
+		sb.WriteString(s)
+		col += n
+	}
+
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch r {
+		case '\\':
+			put(`\\`)
+		case '$':
+			put(`\$`)
+		case '\a':
+			put(`\a`)
+		case '\b':
+			put(`\b`)
+		case '\f':
+			put(`\f`)
+		case '\n':
+			put(`\n`)
+		case '\r':
+			put(`\r`)
+		case '\t':
+			put(`\t`)
+		case '\v':
+			put(`\v`)
+		default:
+			if r == utf8.RuneError || !unicode.IsPrint(r) {
+				for _, c := range []byte(line[i:(i + size)]) {
+					put(fmt.Sprintf("\\%03o", c))
+				}
+			} else {
+				put(string(r))
+			}
+		}
+		i += size
+	}
+	put("$")
+	return sb.String()
+}
+
+func detectLexer(filename, source string) (lexer chroma.Lexer) {
+	if filename != "" {
+		lexer := lexers.Match(filename)
+		if lexer != nil {
+			return lexer
+		}
+	}
+	return lexers.Analyse(source)
+}
+
+func highlightSource(w io.Writer, filename, source, formatter, style string) error {
+	l := detectLexer(filename, source)
+	if l == nil {
+		l = lexers.Fallback
+	}
+	l = chroma.Coalesce(l)
+
+	// Determine formatter.
+	f := formatters.Get(formatter)
+	if f == nil {
+		f = formatters.Fallback
+	}
+
+	// Determine style.
+	s := styles.Get(style)
+	if s == nil {
+		s = styles.Fallback
+	}
+
+	it, err := l.Tokenise(nil, source)
+	if err != nil {
+		return err
+	}
+	return f.Format(w, s, it)
+}
@@ -0,0 +1,30 @@
+package ed
+
+import "testing"
+
+func TestListLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"", 0, "$"},
+		{"plain text", 0, "plain text$"},
+		{"cost $5", 0, `cost \$5$`},
+		{`back\slash`, 0, `back\\slash$`},
+		{"tab\there", 0, `tab\there$`},
+		{"bell\a\b\f\r\v", 0, `bell\a\b\f\r\v$`},
+		{"nul\x00del\x7f", 0, `nul\000del\177$`},
+		{"bad\xffutf8", 0, `bad\377utf8$`},
+		{"héllo", 0, "héllo$"},
+		{"abcdefgh", 5, "abcd\\\nefgh\\\n$"},
+		{"ab\tcd", 4, "ab\\\n\\tc\\\nd$"},
+		{"abc", 0, "abc$"},
+	}
+
+	for _, test := range tests {
+		if got := listLine(test.line, test.width); got != test.want {
+			t.Errorf("listLine(%q, %d) = %q, want %q", test.line, test.width, got, test.want)
+		}
+	}
+}
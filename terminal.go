@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/chzyer/readline"
+
+	"github.com/prologic/ed/pkg/ed"
+)
+
+// readlineTerminal is the interactive Terminal front-end using readline with
+// vi key bindings
+type readlineTerminal struct {
+	*readline.Instance
+
+	// eof is set once input that isn't a terminal has ended, readline
+	// blocks rather than returning io.EOF again
+	eof bool
+}
+
+func newReadlineTerminal(prompt string) (ed.Terminal, error) {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          prompt,
+		InterruptPrompt: ".",
+		EOFPrompt:       "q",
+
+		VimMode: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &readlineTerminal{Instance: rl}, nil
+}
+
+func (t *readlineTerminal) Readline() (string, error) {
+	if t.eof {
+		return "", io.EOF
+	}
+
+	line, err := t.Instance.Readline()
+	switch err {
+	case readline.ErrInterrupt:
+		err = ed.ErrInterrupt
+	case io.EOF:
+		t.eof = !readline.IsTerminal(int(os.Stdin.Fd()))
+	}
+	return line, err
+}
+
+// Size returns the size of the terminal, the editor's window size is its
+// height by default
+func (t *readlineTerminal) Size() (width, height int, err error) {
+	return readline.GetSize(int(os.Stdout.Fd()))
+}